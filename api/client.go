@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+const (
+	DefaultLiveBaseURL = "https://api.live.bilibili.com"
+	DefaultMainBaseURL = "https://api.bilibili.com"
+
+	userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+	referer   = "https://live.bilibili.com/"
+)
+
+// Client B站HTTP接口客户端
+// HTTPClient和各BaseURL均可替换，便于在测试中指向本地的假服务器
+type Client struct {
+	HTTPClient  *http.Client
+	LiveBaseURL string
+	MainBaseURL string
+
+	// Cookie 返回请求时携带的Cookie字符串，为空时以游客身份请求
+	Cookie func() string
+
+	mutex      sync.Mutex
+	mixinKey   string
+	mixinKeyAt time.Time
+	buvid      string
+	gifts      *GiftCache
+}
+
+// NewClient 创建使用默认地址的接口客户端
+func NewClient() *Client {
+	return &Client{
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		LiveBaseURL: DefaultLiveBaseURL,
+		MainBaseURL: DefaultMainBaseURL,
+		gifts:       NewGiftCache(),
+	}
+}
+
+// Gifts 获取礼物元数据缓存
+func (c *Client) Gifts() *GiftCache {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// 直接构造的Client没有缓存，用到时再创建
+	if c.gifts == nil {
+		c.gifts = NewGiftCache()
+	}
+	return c.gifts
+}
+
+// cookieHeader 组装请求Cookie，未登录时补充游客buvid3
+func (c *Client) cookieHeader() string {
+	cookie := ""
+	if c.Cookie != nil {
+		cookie = c.Cookie()
+	}
+
+	c.mutex.Lock()
+	buvid := c.buvid
+	c.mutex.Unlock()
+
+	if buvid != "" && cookieValue(cookie, "buvid3") == "" {
+		if cookie != "" {
+			cookie += "; "
+		}
+		cookie += "buvid3=" + buvid
+	}
+
+	return cookie
+}
+
+// getJSON 发送GET请求并检查返回的code字段
+func (c *Client) getJSON(ctx context.Context, baseURL, path string, params url.Values) (gjson.Result, error) {
+	rawURL := baseURL + path
+	if len(params) > 0 {
+		rawURL += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Referer", referer)
+	if cookie := c.cookieHeader(); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return gjson.Result{}, fmt.Errorf("请求 %s 失败: HTTP %d", path, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+
+	result := gjson.ParseBytes(body)
+	if code := result.Get("code").Int(); code != 0 {
+		return result, &Error{Path: path, Code: code, Message: result.Get("message").String()}
+	}
+
+	return result, nil
+}
+
+// Error 接口返回的业务错误
+type Error struct {
+	Path    string
+	Code    int64
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("接口 %s 返回错误 %d: %s", e.Path, e.Code, e.Message)
+}
+
+// cookieValue 从Cookie字符串中取出指定名称的值
+func cookieValue(cookie, name string) string {
+	request := http.Request{Header: http.Header{}}
+	request.Header.Set("Cookie", cookie)
+	if c, err := request.Cookie(name); err == nil {
+		return c.Value
+	}
+	return ""
+}
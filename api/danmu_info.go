@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// DanmuHost 弹幕服务器地址
+type DanmuHost struct {
+	Host    string `json:"host"`
+	Port    int    `json:"port"`     // 原始TCP端口
+	WssPort int    `json:"wss_port"` // wss端口
+	WsPort  int    `json:"ws_port"`  // ws端口
+}
+
+// DanmuInfo 房间弹幕服务器信息
+type DanmuInfo struct {
+	Token    string      `json:"token"`
+	HostList []DanmuHost `json:"host_list"`
+}
+
+// GetDanmuInfo 获取房间的弹幕认证token和服务器列表
+func (c *Client) GetDanmuInfo(ctx context.Context, roomID int) (*DanmuInfo, error) {
+	// 游客请求没有buvid3时容易触发风控，先确保拿到buvid
+	if _, err := c.GetBuvid(ctx); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("id", strconv.Itoa(roomID))
+	params.Set("type", "0")
+	params.Set("web_location", "444.8")
+
+	signed, err := c.signWbi(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.getJSON(ctx, c.LiveBaseURL, "/xlive/web-room/v1/index/getDanmuInfo", signed)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &DanmuInfo{
+		Token: result.Get("data.token").String(),
+	}
+	for _, host := range result.Get("data.host_list").Array() {
+		info.HostList = append(info.HostList, DanmuHost{
+			Host:    host.Get("host").String(),
+			Port:    int(host.Get("port").Int()),
+			WssPort: int(host.Get("wss_port").Int()),
+			WsPort:  int(host.Get("ws_port").Int()),
+		})
+	}
+
+	if info.Token == "" {
+		return nil, errors.New("获取弹幕服务器信息失败：返回数据缺少token")
+	}
+
+	return info, nil
+}
+
+// GetBuvid 获取设备标识buvid3，Cookie中已有时直接使用，否则向服务器申请并缓存
+func (c *Client) GetBuvid(ctx context.Context) (string, error) {
+	if c.Cookie != nil {
+		if buvid := cookieValue(c.Cookie(), "buvid3"); buvid != "" {
+			return buvid, nil
+		}
+	}
+
+	c.mutex.Lock()
+	buvid := c.buvid
+	c.mutex.Unlock()
+	if buvid != "" {
+		return buvid, nil
+	}
+
+	result, err := c.getJSON(ctx, c.MainBaseURL, "/x/frontend/finger/spi", nil)
+	if err != nil {
+		return "", err
+	}
+
+	buvid = result.Get("data.b_3").String()
+	if buvid == "" {
+		return "", errors.New("获取buvid失败：返回数据缺少b_3")
+	}
+
+	c.mutex.Lock()
+	c.buvid = buvid
+	c.mutex.Unlock()
+
+	return buvid, nil
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// fakeServer 模拟nav、buvid和getDanmuInfo接口
+type fakeServer struct {
+	*httptest.Server
+	navBody  string
+	navCalls int32
+	signErr  string // getDanmuInfo校验签名失败的原因
+}
+
+func newFakeServer(t *testing.T, navBody string) *fakeServer {
+	fake := &fakeServer{navBody: navBody}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/x/frontend/finger/spi", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":0,"data":{"b_3":"test-buvid","b_4":"test-b4"}}`))
+	})
+	mux.HandleFunc("/x/web-interface/nav", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fake.navCalls, 1)
+		w.Write([]byte(fake.navBody))
+	})
+	mux.HandleFunc("/xlive/web-room/v1/index/getDanmuInfo", func(w http.ResponseWriter, r *http.Request) {
+		fake.signErr = checkSignature(r)
+		if fake.signErr != "" {
+			w.Write([]byte(`{"code":-352,"message":"风控校验失败"}`))
+			return
+		}
+		w.Write([]byte(`{"code":0,"message":"0","data":{"group":"live","business_id":0,"refresh_row_factor":0.125,"refresh_rate":100,"max_delay":5000,"token":"test-token","host_list":[{"host":"zj-cn-live-comet.chat.bilibili.com","port":2243,"wss_port":443,"ws_port":2244},{"host":"broadcastlv.chat.bilibili.com","port":2243,"wss_port":443,"ws_port":2244}]}}`))
+	})
+
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// checkSignature 按服务器的方式校验w_rid和Cookie
+func checkSignature(r *http.Request) string {
+	if !strings.Contains(r.Header.Get("Cookie"), "buvid3=test-buvid") {
+		return "缺少buvid3"
+	}
+
+	query := r.URL.Query()
+	wRid := query.Get("w_rid")
+	query.Del("w_rid")
+	if query.Get("wts") == "" || query.Get("id") != "21452505" {
+		return "缺少参数"
+	}
+
+	sum := md5.Sum([]byte(strings.ReplaceAll(query.Encode(), "+", "%20") + testMixinKey))
+	if hex.EncodeToString(sum[:]) != wRid {
+		return "w_rid不匹配"
+	}
+	return ""
+}
+
+func (f *fakeServer) client() *Client {
+	client := NewClient()
+	client.LiveBaseURL = f.URL
+	client.MainBaseURL = f.URL
+	return client
+}
+
+const navWbiImg = `"wbi_img":{"img_url":"https://i0.hdslb.com/bfs/wbi/` + testImgKey + `.png","sub_url":"https://i0.hdslb.com/bfs/wbi/` + testSubKey + `.png"}`
+
+func TestGetDanmuInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		navBody string
+	}{
+		{"已登录", `{"code":0,"message":"0","data":{"isLogin":true,"mid":12345678,` + navWbiImg + `}}`},
+		// 未登录时nav返回-101，但仍会下发WBI密钥
+		{"未登录", `{"code":-101,"message":"账号未登录","data":{"isLogin":false,` + navWbiImg + `}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeServer(t, tt.navBody)
+			client := fake.client()
+
+			info, err := client.GetDanmuInfo(context.Background(), 21452505)
+			if err != nil {
+				t.Fatalf("GetDanmuInfo: %v (%s)", err, fake.signErr)
+			}
+			if info.Token != "test-token" || len(info.HostList) != 2 {
+				t.Errorf("info = %+v", info)
+			}
+			if host := info.HostList[0]; host.Host != "zj-cn-live-comet.chat.bilibili.com" || host.Port != 2243 || host.WssPort != 443 || host.WsPort != 2244 {
+				t.Errorf("HostList[0] = %+v", host)
+			}
+
+			// 混合密钥有缓存，再次请求不会访问nav
+			if _, err := client.GetDanmuInfo(context.Background(), 21452505); err != nil {
+				t.Fatal(err)
+			}
+			if calls := atomic.LoadInt32(&fake.navCalls); calls != 1 {
+				t.Errorf("nav被请求 %d 次", calls)
+			}
+		})
+	}
+}
+
+func TestGetDanmuInfoNavErrors(t *testing.T) {
+	t.Run("其他错误码", func(t *testing.T) {
+		fake := newFakeServer(t, `{"code":-412,"message":"请求被拦截"}`)
+
+		_, err := fake.client().GetDanmuInfo(context.Background(), 21452505)
+		var apiErr *Error
+		if !errors.As(err, &apiErr) || apiErr.Code != -412 {
+			t.Errorf("err = %v, 应为-412错误", err)
+		}
+	})
+
+	t.Run("缺少wbi_img", func(t *testing.T) {
+		fake := newFakeServer(t, `{"code":-101,"message":"账号未登录","data":{"isLogin":false}}`)
+
+		_, err := fake.client().GetDanmuInfo(context.Background(), 21452505)
+		if err == nil || !strings.Contains(err.Error(), "wbi_img") {
+			t.Errorf("err = %v", err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"TianHe-API/utils"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GiftInfo 礼物元数据
+type GiftInfo struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Price    int    `json:"price"`     // 单价，单位由CoinType决定
+	CoinType string `json:"coin_type"` // gold或silver
+	Icon     string `json:"icon"`      // 礼物图标
+}
+
+// GetGiftConfig 获取礼物配置，roomID为0时获取通用礼物
+func (c *Client) GetGiftConfig(ctx context.Context, roomID int) ([]GiftInfo, error) {
+	params := url.Values{}
+	params.Set("platform", "pc")
+	if roomID > 0 {
+		params.Set("room_id", strconv.Itoa(roomID))
+	}
+
+	result, err := c.getJSON(ctx, c.LiveBaseURL, "/xlive/web-room/v1/giftPanel/giftConfig", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var gifts []GiftInfo
+	for _, item := range result.Get("data.list").Array() {
+		gifts = append(gifts, GiftInfo{
+			ID:       int(item.Get("id").Int()),
+			Name:     item.Get("name").String(),
+			Price:    int(item.Get("price").Int()),
+			CoinType: item.Get("coin_type").String(),
+			Icon:     item.Get("img_basic").String(),
+		})
+	}
+
+	if len(gifts) == 0 {
+		return nil, errors.New("获取礼物配置失败：返回数据缺少礼物列表")
+	}
+
+	return gifts, nil
+}
+
+// GiftCache 礼物元数据缓存，可以从接口刷新，也可以从本地文件加载
+type GiftCache struct {
+	mutex     sync.RWMutex
+	gifts     map[int]GiftInfo
+	updatedAt time.Time
+}
+
+// NewGiftCache 创建空的礼物缓存
+func NewGiftCache() *GiftCache {
+	return &GiftCache{
+		gifts: make(map[int]GiftInfo),
+	}
+}
+
+// Get 按礼物ID查询
+func (g *GiftCache) Get(id int) (GiftInfo, bool) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	info, ok := g.gifts[id]
+	return info, ok
+}
+
+// Len 缓存的礼物数
+func (g *GiftCache) Len() int {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return len(g.gifts)
+}
+
+// UpdatedAt 最后一次更新的时间
+func (g *GiftCache) UpdatedAt() time.Time {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	return g.updatedAt
+}
+
+// Update 合并礼物信息，同ID的会被覆盖
+func (g *GiftCache) Update(gifts []GiftInfo) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	for _, info := range gifts {
+		g.gifts[info.ID] = info
+	}
+	g.updatedAt = time.Now()
+}
+
+// Refresh 从接口刷新礼物配置
+func (g *GiftCache) Refresh(ctx context.Context, client *Client, roomID int) error {
+	gifts, err := client.GetGiftConfig(ctx, roomID)
+	if err != nil {
+		return err
+	}
+
+	g.Update(gifts)
+	return nil
+}
+
+// LoadFile 从JSON文件加载礼物配置，文件内容为GiftInfo数组
+func (g *GiftCache) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var gifts []GiftInfo
+	if err := json.Unmarshal(data, &gifts); err != nil {
+		return err
+	}
+
+	g.Update(gifts)
+	return nil
+}
+
+// SaveFile 把缓存的礼物配置保存为JSON文件，供离线时加载
+func (g *GiftCache) SaveFile(path string) error {
+	g.mutex.RLock()
+	gifts := make([]GiftInfo, 0, len(g.gifts))
+	for _, info := range g.gifts {
+		gifts = append(gifts, info)
+	}
+	g.mutex.RUnlock()
+
+	data, err := json.MarshalIndent(gifts, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := utils.CreateFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(data)
+	return err
+}
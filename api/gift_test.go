@@ -0,0 +1,54 @@
+package api
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGiftCacheLoadFile(t *testing.T) {
+	cache := NewGiftCache()
+	if err := cache.LoadFile("testdata/gift_config.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.Len() != 3 || cache.UpdatedAt().IsZero() {
+		t.Errorf("Len = %d, UpdatedAt = %v", cache.Len(), cache.UpdatedAt())
+	}
+	info, ok := cache.Get(31036)
+	if !ok || info.Name != "小花花" || info.Price != 100 || info.CoinType != "gold" || info.Icon == "" {
+		t.Errorf("Get(31036) = %+v, %v", info, ok)
+	}
+	if _, ok := cache.Get(99999); ok {
+		t.Error("不存在的礼物也查到了")
+	}
+
+	if err := cache.LoadFile("testdata/missing.json"); err == nil {
+		t.Error("文件不存在时应返回错误")
+	}
+}
+
+func TestGiftCacheSaveFile(t *testing.T) {
+	cache := NewGiftCache()
+	if err := cache.LoadFile("testdata/gift_config.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config", "gift_config.json")
+	if err := cache.SaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewGiftCache()
+	if err := loaded.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != cache.Len() {
+		t.Fatalf("保存后重新加载得到 %d 个礼物, want %d", loaded.Len(), cache.Len())
+	}
+	for _, id := range []int{31036, 1, 10003} {
+		want, _ := cache.Get(id)
+		if got, ok := loaded.Get(id); !ok || got != want {
+			t.Errorf("Get(%d) = %+v, want %+v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+)
+
+// GuardInfo 大航海成员信息
+type GuardInfo struct {
+	UserID     int64  `json:"uid"`
+	UserName   string `json:"username"`
+	GuardLevel int    `json:"guard_level"` // 1总督 2提督 3舰长
+}
+
+// 大航海列表每页的数量
+const guardPageSize = 30
+
+// GetGuardList 获取房间的大航海成员列表，anchorUID为主播UID
+func (c *Client) GetGuardList(ctx context.Context, roomID int, anchorUID int64) ([]GuardInfo, error) {
+	var guards []GuardInfo
+
+	for page, pages := 1, 1; page <= pages; page++ {
+		params := url.Values{}
+		params.Set("roomid", strconv.Itoa(roomID))
+		params.Set("ruid", strconv.FormatInt(anchorUID, 10))
+		params.Set("page", strconv.Itoa(page))
+		params.Set("page_size", strconv.Itoa(guardPageSize))
+
+		result, err := c.getJSON(ctx, c.LiveBaseURL, "/xlive/app-room/v2/guardTab/topList", params)
+		if err != nil {
+			return nil, err
+		}
+
+		pages = int(result.Get("data.info.page").Int())
+
+		// 前三名只在第一页的top3中
+		items := result.Get("data.list").Array()
+		if page == 1 {
+			items = append(result.Get("data.top3").Array(), items...)
+		}
+
+		for _, item := range items {
+			guards = append(guards, GuardInfo{
+				UserID:     item.Get("uid").Int(),
+				UserName:   item.Get("username").String(),
+				GuardLevel: int(item.Get("guard_level").Int()),
+			})
+		}
+	}
+
+	return guards, nil
+}
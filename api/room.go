@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// RoomInit 房间初始化信息
+type RoomInit struct {
+	RoomID     int   `json:"room_id"`     // 真实房间号
+	ShortID    int   `json:"short_id"`    // 短号，没有短号时为0
+	UID        int64 `json:"uid"`         // 主播UID
+	LiveStatus int   `json:"live_status"` // 0未开播 1直播中 2轮播中
+}
+
+// GetRoomInit 获取房间初始化信息，id可以是短号或真实房间号
+func (c *Client) GetRoomInit(ctx context.Context, id int) (*RoomInit, error) {
+	params := url.Values{}
+	params.Set("id", strconv.Itoa(id))
+
+	result, err := c.getJSON(ctx, c.LiveBaseURL, "/room/v1/Room/room_init", params)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &RoomInit{
+		RoomID:     int(result.Get("data.room_id").Int()),
+		ShortID:    int(result.Get("data.short_id").Int()),
+		UID:        result.Get("data.uid").Int(),
+		LiveStatus: int(result.Get("data.live_status").Int()),
+	}
+
+	if info.RoomID == 0 {
+		return nil, errors.New("获取房间信息失败：返回数据缺少room_id")
+	}
+
+	return info, nil
+}
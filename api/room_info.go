@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RoomInfo 直播间信息
+type RoomInfo struct {
+	RoomID         int       `json:"room_id"`
+	UID            int64     `json:"uid"`         // 主播UID
+	Title          string    `json:"title"`       // 直播标题
+	LiveStatus     int       `json:"live_status"` // 0未开播 1直播中 2轮播中
+	LiveTime       time.Time `json:"live_time"`   // 本场开播时间，未开播时为零值
+	AreaName       string    `json:"area_name"`
+	ParentAreaName string    `json:"parent_area_name"`
+	Cover          string    `json:"cover"` // 直播封面
+}
+
+// 接口返回的开播时间为北京时间
+var liveTimeZone = time.FixedZone("CST", 8*3600)
+
+// GetRoomInfo 获取直播间信息
+func (c *Client) GetRoomInfo(ctx context.Context, roomID int) (*RoomInfo, error) {
+	params := url.Values{}
+	params.Set("room_id", strconv.Itoa(roomID))
+
+	result, err := c.getJSON(ctx, c.LiveBaseURL, "/room/v1/Room/get_info", params)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &RoomInfo{
+		RoomID:         int(result.Get("data.room_id").Int()),
+		UID:            result.Get("data.uid").Int(),
+		Title:          result.Get("data.title").String(),
+		LiveStatus:     int(result.Get("data.live_status").Int()),
+		AreaName:       result.Get("data.area_name").String(),
+		ParentAreaName: result.Get("data.parent_area_name").String(),
+		Cover:          result.Get("data.user_cover").String(),
+	}
+
+	// 未开播时为0000-00-00 00:00:00，解析失败保持零值
+	liveTime, err := time.ParseInLocation("2006-01-02 15:04:05", result.Get("data.live_time").String(), liveTimeZone)
+	if err == nil && liveTime.Year() > 1 {
+		info.LiveTime = liveTime
+	}
+
+	if info.RoomID == 0 {
+		return nil, errors.New("获取直播间信息失败：返回数据缺少room_id")
+	}
+
+	return info, nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+// 数值来自公开的WBI签名文档示例
+const (
+	testImgKey   = "7cd084941338484aae1ad9425b84077c"
+	testSubKey   = "4932caff0ff746eab6f01bf08b70ac45"
+	testMixinKey = "ea1db124af3c7062474693fa704f4ff8"
+)
+
+func TestMixinKey(t *testing.T) {
+	if key := mixinKey(testImgKey + testSubKey); key != testMixinKey {
+		t.Errorf("mixinKey = %s, want %s", key, testMixinKey)
+	}
+}
+
+func TestSignParams(t *testing.T) {
+	params := url.Values{}
+	params.Set("foo", "114")
+	params.Set("bar", "514")
+	params.Set("zab", "1919810")
+
+	signed := signParams(params, testMixinKey, time.Unix(1702204169, 0))
+
+	if wts := signed.Get("wts"); wts != "1702204169" {
+		t.Errorf("wts = %s", wts)
+	}
+	if wRid := signed.Get("w_rid"); wRid != "8f6f2b5b3d485fe1886cec6a0be8c5d4" {
+		t.Errorf("w_rid = %s", wRid)
+	}
+	if params.Get("wts") != "" {
+		t.Error("signParams不应修改传入的参数")
+	}
+}
+
+func TestSignParamsSanitize(t *testing.T) {
+	params := url.Values{}
+	params.Set("keyword", "(hello)*world!'")
+
+	signed := signParams(params, testMixinKey, time.Unix(1702204169, 0))
+	if keyword := signed.Get("keyword"); keyword != "helloworld" {
+		t.Errorf("keyword = %q", keyword)
+	}
+}
+
+func TestKeyFromURL(t *testing.T) {
+	tests := map[string]string{
+		"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png": testImgKey,
+		"": "",
+	}
+	for rawURL, want := range tests {
+		if key := keyFromURL(rawURL); key != want {
+			t.Errorf("keyFromURL(%q) = %q, want %q", rawURL, key, want)
+		}
+	}
+}
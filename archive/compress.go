@@ -0,0 +1,46 @@
+package archive
+
+import (
+	"TianHe-API/utils"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// compressFile 把文件压缩为同名的.gz文件，成功后删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := utils.CreateFile(path + ".gz")
+	if err != nil {
+		return err
+	}
+
+	if err := writeGzip(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(dst.Name())
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
+
+func writeGzip(dst *os.File, src io.Reader) error {
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return dst.Sync()
+}
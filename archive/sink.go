@@ -0,0 +1,344 @@
+package archive
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/utils"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrClosed 归档已关闭
+var ErrClosed = errors.New("归档已关闭")
+
+// Options 归档参数
+type Options struct {
+	Dir          string        // 归档根目录，文件按 日期/房间号 分目录存放
+	MaxSize      int64         // 单个文件的最大字节数，<=0表示不按大小切分
+	MaxAge       time.Duration // 单个文件最多写入多久，<=0表示只在跨天时切分
+	SyncInterval time.Duration // 刷盘间隔，<=0表示只在切分和关闭时刷盘
+	Compress     bool          // 切分后是否gzip压缩已关闭的文件
+	QueueSize    int           // 待写入事件的队列长度，满时丢弃新事件
+}
+
+// Sink 把事件按房间和日期写入JSON Lines文件，每行一个事件
+//
+// 事件先进入队列，由单独的协程写入缓冲区并按SyncInterval定时刷盘，不阻塞事件总线
+type Sink struct {
+	options Options
+	queue   chan *event.Event
+	dropped uint64
+
+	mutex  sync.Mutex
+	files  map[int]*roomFile
+	closed bool
+
+	done      chan struct{}
+	closeOnce sync.Once
+	stopped   chan struct{}  // 写入协程退出时关闭
+	wg        sync.WaitGroup // 压缩协程
+}
+
+// roomFile 一个房间正在写入的文件
+type roomFile struct {
+	path     string
+	date     string
+	file     *os.File
+	writer   *bufio.Writer
+	size     int64
+	openedAt time.Time
+	dirty    bool // 上次刷盘后是否有新写入
+}
+
+// NewSink 创建归档
+func NewSink(options Options) *Sink {
+	if options.QueueSize <= 0 {
+		options.QueueSize = 4096
+	}
+
+	s := &Sink{
+		options: options,
+		queue:   make(chan *event.Event, options.QueueSize),
+		files:   make(map[int]*roomFile),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Handle 把事件放入写入队列，可直接传给Bus.SubscribeFunc
+func (s *Sink) Handle(e *event.Event) {
+	select {
+	case <-s.done:
+	case s.queue <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Dropped 因队列已满而丢弃的事件数
+func (s *Sink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Write 立即写入事件，不经过队列
+func (s *Sink) Write(e *event.Event) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("序列化事件失败: %v", err)
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrClosed
+	}
+
+	f, err := s.fileFor(e.RoomID, e.Time, int64(len(line)))
+	if err != nil {
+		return err
+	}
+
+	n, err := f.writer.Write(line)
+	f.size += int64(n)
+	f.dirty = true
+	return err
+}
+
+// Sync 把所有房间的缓冲区写入磁盘
+func (s *Sink) Sync() error {
+	s.mutex.Lock()
+	files, firstErr := s.flush()
+	s.mutex.Unlock()
+
+	for _, file := range files {
+		if err := file.Sync(); err != nil && !errors.Is(err, os.ErrClosed) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Close 写入队列中剩余的事件，关闭所有文件并等待压缩完成
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	<-s.stopped
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+
+	var firstErr error
+	for roomID, f := range s.files {
+		if err := s.rotate(roomID, f); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.mutex.Unlock()
+
+	s.wg.Wait()
+	return firstErr
+}
+
+// fileFor 获取房间当前应写入的文件，跨天、超过大小或时长时切换到新文件，调用方需持有锁
+func (s *Sink) fileFor(roomID int, t time.Time, pending int64) (*roomFile, error) {
+	date := t.Local().Format("2006-01-02")
+
+	if f, ok := s.files[roomID]; ok {
+		expired := s.options.MaxAge > 0 && time.Since(f.openedAt) >= s.options.MaxAge
+		full := s.options.MaxSize > 0 && f.size > 0 && f.size+pending > s.options.MaxSize
+		if f.date == date && !expired && !full {
+			return f, nil
+		}
+		if err := s.rotate(roomID, f); err != nil {
+			utils.Logger.Warnf("房间 %d 关闭归档文件失败: %v", roomID, err)
+		}
+	}
+
+	f, err := s.open(roomID, date, t)
+	if err != nil {
+		return nil, err
+	}
+	s.files[roomID] = f
+	return f, nil
+}
+
+// open 创建新文件，同一秒内多次切分时加序号避免覆盖
+func (s *Sink) open(roomID int, date string, t time.Time) (*roomFile, error) {
+	dir := filepath.Join(s.options.Dir, date, fmt.Sprint(roomID))
+	base := fmt.Sprintf("%d-%s", roomID, t.Local().Format("150405"))
+
+	path := filepath.Join(dir, base+".jsonl")
+	for i := 1; utils.FileExists(path) || utils.FileExists(path+".gz"); i++ {
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.jsonl", base, i))
+	}
+
+	file, err := utils.CreateFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("创建归档文件失败: %v", err)
+	}
+
+	return &roomFile{
+		path:     path,
+		date:     date,
+		file:     file,
+		writer:   bufio.NewWriter(file),
+		openedAt: time.Now(),
+	}, nil
+}
+
+// rotate 关闭房间当前的文件并在后台压缩，调用方需持有锁
+func (s *Sink) rotate(roomID int, f *roomFile) error {
+	delete(s.files, roomID)
+
+	err := f.sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if f.size == 0 {
+		return os.Remove(f.path)
+	}
+
+	if s.options.Compress {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if err := compressFile(f.path); err != nil {
+				utils.Logger.Warnf("压缩归档文件 %s 失败: %v", f.path, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// run 从队列中取出事件写入，定时刷盘，并关闭已超过时长的文件，避免没有新事件的房间一直占用文件
+func (s *Sink) run() {
+	defer close(s.stopped)
+
+	interval := s.options.SyncInterval
+	if interval <= 0 || (s.options.MaxAge > 0 && s.options.MaxAge < interval) {
+		interval = s.options.MaxAge
+	}
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case e := <-s.queue:
+			s.write(e)
+		case <-tick:
+			s.tick()
+		case <-s.done:
+			for {
+				select {
+				case e := <-s.queue:
+					s.write(e)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// write 写入队列中的事件，失败时只记录日志
+func (s *Sink) write(e *event.Event) {
+	if err := s.Write(e); err != nil && !errors.Is(err, ErrClosed) {
+		utils.Logger.Errorf("房间 %d 归档事件失败: %v", e.RoomID, err)
+	}
+}
+
+func (s *Sink) tick() {
+	s.mutex.Lock()
+	if s.options.MaxAge > 0 {
+		for roomID, f := range s.files {
+			if time.Since(f.openedAt) >= s.options.MaxAge {
+				if err := s.rotate(roomID, f); err != nil {
+					utils.Logger.Warnf("房间 %d 关闭归档文件失败: %v", roomID, err)
+				}
+			}
+		}
+	}
+	if s.options.SyncInterval <= 0 {
+		s.mutex.Unlock()
+		return
+	}
+	files, err := s.flush()
+	s.mutex.Unlock()
+
+	if err != nil {
+		utils.Logger.Warnf("归档写入文件失败: %v", err)
+	}
+
+	// fsync可能很慢，在锁外进行，期间文件被切分关闭时忽略
+	for roomID, file := range files {
+		if err := file.Sync(); err != nil && !errors.Is(err, os.ErrClosed) {
+			utils.Logger.Warnf("房间 %d 归档刷盘失败: %v", roomID, err)
+		}
+	}
+}
+
+// flush 把所有房间的缓冲区写入文件，返回有新写入、需要fsync的文件，调用方需持有锁
+func (s *Sink) flush() (map[int]*os.File, error) {
+	files := make(map[int]*os.File)
+	var firstErr error
+	for roomID, f := range s.files {
+		flushed, err := f.flush()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("房间 %d: %v", roomID, err)
+			}
+			continue
+		}
+		if flushed {
+			files[roomID] = f.file
+		}
+	}
+	return files, firstErr
+}
+
+// flush 把缓冲区写入文件，返回是否有新写入
+func (f *roomFile) flush() (bool, error) {
+	if !f.dirty {
+		return false, nil
+	}
+
+	if err := f.writer.Flush(); err != nil {
+		return false, err
+	}
+	f.dirty = false
+	return true, nil
+}
+
+// sync 写出缓冲区并调用fsync，没有新写入时跳过
+func (f *roomFile) sync() error {
+	flushed, err := f.flush()
+	if err != nil || !flushed {
+		return err
+	}
+	return f.file.Sync()
+}
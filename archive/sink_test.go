@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/utils"
+	"bufio"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
+
+func danmuEvent(roomID int, text string) *event.Event {
+	return &event.Event{
+		Type:   event.TypeDanmu,
+		RoomID: roomID,
+		Time:   time.Now(),
+		Data:   &model.DanmuMessage{Text: text},
+	}
+}
+
+// countLines 统计目录下所有归档文件的行数
+func countLines(t *testing.T, dir string) int {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*", "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			lines++
+		}
+		file.Close()
+	}
+	return lines
+}
+
+func TestSinkWritesQueuedEventsOnClose(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewSink(Options{Dir: dir, SyncInterval: time.Hour})
+
+	for i := 0; i < 100; i++ {
+		sink.Handle(danmuEvent(1+i%2, "你好"))
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := countLines(t, dir); lines != 100 {
+		t.Errorf("归档了 %d 行, want 100", lines)
+	}
+	if err := sink.Write(danmuEvent(1, "关闭后")); err != ErrClosed {
+		t.Errorf("关闭后写入 err = %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("重复关闭 err = %v", err)
+	}
+}
+
+func TestSinkHandleDoesNotBlock(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewSink(Options{Dir: dir, QueueSize: 10})
+
+	// 写入协程拿不到锁时，Handle仍然立即返回，队列满后丢弃
+	sink.mutex.Lock()
+	returned := make(chan struct{})
+	go func() {
+		defer close(returned)
+		for i := 0; i < 50; i++ {
+			sink.Handle(danmuEvent(1, "你好"))
+		}
+	}()
+
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Handle被写入阻塞")
+	}
+	sink.mutex.Unlock()
+
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 写入协程可能已取出一条事件在等待锁
+	dropped := int(sink.Dropped())
+	if dropped < 39 || dropped > 40 {
+		t.Errorf("丢弃了 %d 个事件", dropped)
+	}
+	if lines := countLines(t, dir); lines != 50-dropped {
+		t.Errorf("归档了 %d 行, 丢弃 %d", lines, dropped)
+	}
+}
+
+func TestSinkSyncFlushesBuffer(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewSink(Options{Dir: dir})
+	defer sink.Close()
+
+	if err := sink.Write(danmuEvent(1, "你好")); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if lines := countLines(t, dir); lines != 1 {
+		t.Errorf("Sync后文件中有 %d 行", lines)
+	}
+}
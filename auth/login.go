@@ -3,12 +3,12 @@ package auth
 import (
 	"TianHe-API/config"
 	"TianHe-API/utils"
-	"crypto/md5"
-	"encoding/hex"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,7 +26,7 @@ var client = &http.Client{
 }
 
 // 检查是否已登录
-func IsLoggedIn() bool {
+func IsLoggedIn(ctx context.Context) bool {
 	cookie, err := config.LoadCookie("config/cookie.json")
 	if err != nil {
 		return false
@@ -38,12 +38,12 @@ func IsLoggedIn() bool {
 	}
 
 	// 验证cookie有效性
-	return validateCookie(cookie)
+	return validateCookie(ctx, cookie)
 }
 
 // 验证cookie有效性
-func validateCookie(cookie *config.Cookie) bool {
-	req, err := http.NewRequest("GET", NavURL, nil)
+func validateCookie(ctx context.Context, cookie *config.Cookie) bool {
+	req, err := http.NewRequestWithContext(ctx, "GET", NavURL, nil)
 	if err != nil {
 		return false
 	}
@@ -68,13 +68,17 @@ func validateCookie(cookie *config.Cookie) bool {
 
 // 格式化cookie
 func formatCookie(cookie *config.Cookie) string {
-	return fmt.Sprintf("SESSDATA=%s; bili_jct=%s; DedeUserID=%s; DedeUserID__ckMd5=%s; sid=%s",
+	str := fmt.Sprintf("SESSDATA=%s; bili_jct=%s; DedeUserID=%s; DedeUserID__ckMd5=%s; sid=%s",
 		cookie.SESSDATA, cookie.BiliJct, cookie.DedeUserID, cookie.DedeUserID__ckMd5, cookie.Sid)
+	if cookie.Buvid3 != "" {
+		str += "; buvid3=" + cookie.Buvid3
+	}
+	return str
 }
 
 // 获取登录token
-func getLoginToken() (string, string, error) {
-	req, err := http.NewRequest("GET", LoginURL, nil)
+func getLoginToken(ctx context.Context) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", LoginURL, nil)
 	if err != nil {
 		return "", "", err
 	}
@@ -103,13 +107,13 @@ func getLoginToken() (string, string, error) {
 	return qrcodeKey, qrcodeURL, nil
 }
 
-// 轮询登录状态
-func pollLogin(qrcodeKey string) (*config.Cookie, error) {
+// 轮询登录状态，直到登录成功、二维码过期或ctx被取消
+func pollLogin(ctx context.Context, qrcodeKey string) (*config.Cookie, error) {
 	data := url.Values{}
 	data.Set("qrcode_key", qrcodeKey)
 
 	for {
-		req, err := http.NewRequest("POST", PollURL, strings.NewReader(data.Encode()))
+		req, err := http.NewRequestWithContext(ctx, "POST", PollURL, strings.NewReader(data.Encode()))
 		if err != nil {
 			return nil, err
 		}
@@ -145,7 +149,11 @@ func pollLogin(qrcodeKey string) (*config.Cookie, error) {
 			return nil, fmt.Errorf("登录失败: %s", result.Get("message").String())
 		}
 
-		time.Sleep(2 * time.Second)
+		select {
+		case <-time.After(2 * time.Second):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	}
 }
 
@@ -167,6 +175,8 @@ func parseCookieFromResponse(resp *http.Response) *config.Cookie {
 			cookie.DedeUserID__ckMd5 = c.Value
 		case "sid":
 			cookie.Sid = c.Value
+		case "buvid3":
+			cookie.Buvid3 = c.Value
 		}
 	}
 
@@ -174,13 +184,13 @@ func parseCookieFromResponse(resp *http.Response) *config.Cookie {
 }
 
 // 获取当前用户信息
-func GetUserInfo() (map[string]interface{}, error) {
+func GetUserInfo(ctx context.Context) (map[string]interface{}, error) {
 	cookie, err := config.LoadCookie("config/cookie.json")
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("GET", NavURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", NavURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -223,15 +233,17 @@ func GetCookieString() string {
 	return formatCookie(cookie)
 }
 
-// 生成WS认证token
-func GenerateToken(roomID int) string {
+// 获取登录用户UID，未登录时返回0
+func GetUID() int64 {
 	cookie, err := config.LoadCookie("config/cookie.json")
 	if err != nil {
-		return ""
+		return 0
+	}
+
+	uid, err := strconv.ParseInt(cookie.DedeUserID, 10, 64)
+	if err != nil {
+		return 0
 	}
 
-	// 使用SESSDATA和roomID生成token
-	h := md5.New()
-	h.Write([]byte(fmt.Sprintf("%s%d", cookie.SESSDATA, roomID)))
-	return hex.EncodeToString(h.Sum(nil))
+	return uid
 }
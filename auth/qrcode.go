@@ -2,16 +2,17 @@ package auth
 
 import (
 	"TianHe-API/utils"
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/skip2/go-qrcode"
 )
 
-// 二维码登录
-func QRCodeLogin() error {
+// 二维码登录，ctx被取消时放弃等待扫码
+func QRCodeLogin(ctx context.Context) error {
 	// 获取二维码
-	qrcodeKey, qrcodeURL, err := getLoginToken()
+	qrcodeKey, qrcodeURL, err := getLoginToken(ctx)
 	if err != nil {
 		return err
 	}
@@ -26,7 +27,7 @@ func QRCodeLogin() error {
 	utils.Logger.Info("二维码URL: " + qrcodeURL)
 
 	// 轮询登录状态
-	cookie, err := pollLogin(qrcodeKey)
+	cookie, err := pollLogin(ctx, qrcodeKey)
 	if err != nil {
 		return err
 	}
@@ -43,7 +44,7 @@ func QRCodeLogin() error {
 	}
 
 	// 显示用户信息
-	userInfo, err := GetUserInfo()
+	userInfo, err := GetUserInfo(ctx)
 	if err == nil {
 		utils.Logger.Infof("登录用户: %s (UID: %v)", userInfo["uname"], userInfo["uid"])
 	}
@@ -0,0 +1,186 @@
+package client
+
+import (
+	"TianHe-API/config"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// 熔断器状态
+const (
+	BreakerClosed   = "closed"    // 正常重连
+	BreakerOpen     = "open"      // 连续失败过多，冷却中
+	BreakerHalfOpen = "half-open" // 冷却结束，允许试探一次
+)
+
+// BackoffPolicy 重连策略
+type BackoffPolicy struct {
+	BaseDelay        time.Duration // 首次重试的等待时间
+	MaxDelay         time.Duration // 等待时间上限
+	Multiplier       float64       // 每次失败后等待时间的倍数
+	Jitter           float64       // 随机抖动比例，0.2表示上下浮动20%
+	MaxRetries       int           // 连续失败多少次后放弃，<=0表示无限重试
+	ResetAfter       time.Duration // 连接稳定多久后清零失败次数
+	BreakerThreshold int           // 连续失败多少次后熔断，<=0表示不熔断
+	BreakerCooldown  time.Duration // 熔断后的冷却时间
+}
+
+// NewBackoffPolicy 从配置生成重连策略
+func NewBackoffPolicy(cfg *config.Config) BackoffPolicy {
+	return BackoffPolicy{
+		BaseDelay:        time.Duration(cfg.RetryDelay) * time.Second,
+		MaxDelay:         time.Duration(cfg.RetryMaxDelay) * time.Second,
+		Multiplier:       cfg.RetryMultiplier,
+		Jitter:           cfg.RetryJitter,
+		MaxRetries:       cfg.MaxRetries,
+		ResetAfter:       time.Duration(cfg.RetryResetAfter) * time.Second,
+		BreakerThreshold: cfg.BreakerThreshold,
+		BreakerCooldown:  time.Duration(cfg.BreakerCooldown) * time.Second,
+	}
+}
+
+// BackoffStatus 重连状态快照
+type BackoffStatus struct {
+	Failures  int       `json:"failures"`   // 连续失败次数
+	Breaker   string    `json:"breaker"`    // 熔断器状态
+	NextRetry time.Time `json:"next_retry"` // 下次重试时间，未在等待时为零值
+	GaveUp    bool      `json:"gave_up"`    // 是否已放弃重连
+}
+
+// Backoff 单个房间的指数退避与熔断状态
+type Backoff struct {
+	policy BackoffPolicy
+	clock  Clock
+	rand   func() float64
+
+	mutex       sync.Mutex
+	failures    int
+	connectedAt time.Time
+	openedAt    time.Time
+	open        bool
+	nextRetry   time.Time
+	gaveUp      bool
+}
+
+// NewBackoff 创建退避状态，clock为nil时使用系统时钟
+func NewBackoff(policy BackoffPolicy, clock Clock) *Backoff {
+	if clock == nil {
+		clock = RealClock
+	}
+
+	return &Backoff{
+		policy: policy,
+		clock:  clock,
+		rand:   rand.Float64,
+	}
+}
+
+// Connected 记录连接成功，熔断器恢复为关闭状态
+// 失败次数要等连接稳定ResetAfter之后才会清零，避免频繁闪断时退避失效
+func (b *Backoff) Connected() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.connectedAt = b.clock.Now()
+	b.open = false
+	b.nextRetry = time.Time{}
+}
+
+// Disconnected 记录连接断开，返回重连前的等待时间以及是否应放弃
+func (b *Backoff) Disconnected() (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.connectedAt.IsZero() && b.clock.Now().Sub(b.connectedAt) >= b.policy.ResetAfter {
+		b.failures = 0
+	}
+	b.connectedAt = time.Time{}
+
+	return b.fail()
+}
+
+// Failure 记录一次连接失败，返回重连前的等待时间以及是否应放弃
+func (b *Backoff) Failure() (time.Duration, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.fail()
+}
+
+func (b *Backoff) fail() (time.Duration, bool) {
+	now := b.clock.Now()
+	halfOpen := b.state(now) == BreakerHalfOpen
+	b.failures++
+
+	if b.policy.MaxRetries > 0 && b.failures >= b.policy.MaxRetries {
+		b.gaveUp = true
+		b.nextRetry = time.Time{}
+		return 0, true
+	}
+
+	var delay time.Duration
+	if halfOpen || (b.policy.BreakerThreshold > 0 && b.failures >= b.policy.BreakerThreshold) {
+		// 试探失败或连续失败过多，进入冷却
+		b.open = true
+		b.openedAt = now
+		delay = b.policy.BreakerCooldown
+	} else {
+		delay = b.delay(b.failures)
+	}
+
+	b.nextRetry = now.Add(delay)
+	return delay, false
+}
+
+// delay 计算第n次失败后的等待时间
+func (b *Backoff) delay(failures int) time.Duration {
+	multiplier := b.policy.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(b.policy.BaseDelay) * math.Pow(multiplier, float64(failures-1))
+	if b.policy.Jitter > 0 {
+		delay *= 1 + b.policy.Jitter*(2*b.rand()-1)
+	}
+
+	if b.policy.MaxDelay > 0 && delay > float64(b.policy.MaxDelay) {
+		delay = float64(b.policy.MaxDelay)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	return time.Duration(delay)
+}
+
+// state 当前熔断器状态，冷却结束后自动进入半开状态
+func (b *Backoff) state(now time.Time) string {
+	if !b.open {
+		return BreakerClosed
+	}
+	if now.Sub(b.openedAt) >= b.policy.BreakerCooldown {
+		return BreakerHalfOpen
+	}
+	return BreakerOpen
+}
+
+// Status 获取重连状态快照
+func (b *Backoff) Status() BackoffStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.clock.Now()
+	status := BackoffStatus{
+		Failures: b.failures,
+		Breaker:  b.state(now),
+		GaveUp:   b.gaveUp,
+	}
+	if b.nextRetry.After(now) {
+		status.NextRetry = b.nextRetry
+	}
+
+	return status
+}
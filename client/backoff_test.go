@@ -0,0 +1,160 @@
+package client
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func newTestBackoff(policy BackoffPolicy) (*Backoff, *fakeClock) {
+	clock := newFakeClock()
+	return NewBackoff(policy, clock), clock
+}
+
+func TestBackoffGrowthAndCap(t *testing.T) {
+	b, _ := newTestBackoff(BackoffPolicy{
+		BaseDelay:  time.Second,
+		MaxDelay:   10 * time.Second,
+		Multiplier: 2,
+	})
+
+	want := []time.Duration{1, 2, 4, 8, 10, 10}
+	for i, w := range want {
+		delay, giveUp := b.Failure()
+		if giveUp {
+			t.Fatalf("第%d次失败不应放弃", i+1)
+		}
+		if delay != w*time.Second {
+			t.Errorf("第%d次失败等待 %v, want %v", i+1, delay, w*time.Second)
+		}
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b, _ := newTestBackoff(BackoffPolicy{
+		BaseDelay:  10 * time.Second,
+		MaxDelay:   time.Minute,
+		Multiplier: 1,
+		Jitter:     0.2,
+	})
+
+	// 随机数取两端时分别为下浮和上浮20%
+	for _, tt := range []struct {
+		rand float64
+		want time.Duration
+	}{
+		{0, 8 * time.Second},
+		{0.5, 10 * time.Second},
+		{1, 12 * time.Second},
+	} {
+		b.rand = func() float64 { return tt.rand }
+		if delay, _ := b.Failure(); delay != tt.want {
+			t.Errorf("rand = %v 时等待 %v, want %v", tt.rand, delay, tt.want)
+		}
+	}
+
+	b.rand = rand.Float64
+	for i := 0; i < 1000; i++ {
+		delay, _ := b.Failure()
+		if delay < 8*time.Second || delay > 12*time.Second {
+			t.Fatalf("等待 %v 超出抖动范围", delay)
+		}
+	}
+}
+
+func TestBackoffMaxRetries(t *testing.T) {
+	b, _ := newTestBackoff(BackoffPolicy{
+		BaseDelay:  time.Second,
+		Multiplier: 2,
+		MaxRetries: 3,
+	})
+
+	for i := 1; i < 3; i++ {
+		if _, giveUp := b.Failure(); giveUp {
+			t.Fatalf("第%d次失败不应放弃", i)
+		}
+	}
+	if _, giveUp := b.Failure(); !giveUp {
+		t.Fatal("达到MaxRetries后应放弃")
+	}
+
+	status := b.Status()
+	if !status.GaveUp || status.Failures != 3 || !status.NextRetry.IsZero() {
+		t.Errorf("Status = %+v", status)
+	}
+}
+
+func TestBackoffResetAfter(t *testing.T) {
+	policy := BackoffPolicy{
+		BaseDelay:  time.Second,
+		Multiplier: 2,
+		ResetAfter: time.Minute,
+	}
+
+	t.Run("连接稳定后清零", func(t *testing.T) {
+		b, clock := newTestBackoff(policy)
+		b.Failure()
+		b.Failure()
+		b.Connected()
+		clock.Advance(time.Minute)
+
+		if delay, _ := b.Disconnected(); delay != time.Second {
+			t.Errorf("等待 %v, want 1s", delay)
+		}
+	})
+
+	t.Run("闪断时继续退避", func(t *testing.T) {
+		b, clock := newTestBackoff(policy)
+		b.Failure()
+		b.Failure()
+		b.Connected()
+		clock.Advance(59 * time.Second)
+
+		if delay, _ := b.Disconnected(); delay != 4*time.Second {
+			t.Errorf("等待 %v, want 4s", delay)
+		}
+	})
+}
+
+func TestBackoffBreaker(t *testing.T) {
+	b, clock := newTestBackoff(BackoffPolicy{
+		BaseDelay:        time.Second,
+		Multiplier:       2,
+		BreakerThreshold: 3,
+		BreakerCooldown:  10 * time.Minute,
+	})
+
+	b.Failure()
+	b.Failure()
+	if state := b.Status().Breaker; state != BreakerClosed {
+		t.Fatalf("未达到阈值时状态为 %s", state)
+	}
+
+	// 达到阈值后熔断，等待冷却时间
+	if delay, _ := b.Failure(); delay != 10*time.Minute {
+		t.Errorf("熔断后等待 %v, want 10m", delay)
+	}
+	status := b.Status()
+	if status.Breaker != BreakerOpen || !status.NextRetry.Equal(clock.Now().Add(10*time.Minute)) {
+		t.Errorf("Status = %+v", status)
+	}
+
+	// 冷却结束进入半开，试探失败再次熔断
+	clock.Advance(10 * time.Minute)
+	if state := b.Status().Breaker; state != BreakerHalfOpen {
+		t.Fatalf("冷却结束后状态为 %s", state)
+	}
+	if delay, _ := b.Failure(); delay != 10*time.Minute {
+		t.Errorf("试探失败后等待 %v, want 10m", delay)
+	}
+	if state := b.Status().Breaker; state != BreakerOpen {
+		t.Fatalf("试探失败后状态为 %s", state)
+	}
+
+	// 试探成功后关闭
+	clock.Advance(10 * time.Minute)
+	b.Connected()
+	if state := b.Status().Breaker; state != BreakerClosed {
+		t.Errorf("试探成功后状态为 %s", state)
+	}
+}
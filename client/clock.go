@@ -0,0 +1,23 @@
+package client
+
+import "time"
+
+// Clock 时间来源，测试中可替换为假时钟
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock 使用系统时间
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// RealClock 系统时钟
+var RealClock Clock = realClock{}
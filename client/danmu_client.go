@@ -1,102 +1,337 @@
 package client
 
 import (
+	"TianHe-API/api"
 	"TianHe-API/auth"
+	"TianHe-API/config"
+	"TianHe-API/event"
 	"TianHe-API/handler"
+	"TianHe-API/model"
 	"TianHe-API/protocol"
 	"TianHe-API/utils"
-	"net/url"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
 	"sync"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 type DanmuClient struct {
-	roomID    int
-	conn      *websocket.Conn
-	done      chan struct{}
-	handlers  map[string]handler.MessageHandler
-	connected bool
-	mutex     sync.RWMutex
+	roomID     int
+	shortID    int
+	protover   int
+	api        *api.Client
+	hosts      *hostPool
+	factory    TransportFactory
+	transport  Transport
+	endpoint   Endpoint
+	done       chan struct{}
+	builtin    map[string]handler.MessageHandler
+	fallback   handler.MessageHandler // 处理没有注册处理器的命令
+	handlers   *handler.Registry      // 本房间注册的处理器和中间件
+	global     *handler.Registry      // 所有房间共用的处理器和中间件，可以为nil
+	chains     dispatchChains
+	queue      *dispatchQueue
+	gifts      *handler.GiftAggregator
+	superChats *handler.SuperChatTracker
+	guards     *handler.GuardRoster
+	guardBuys  *handler.GuardHandler
+	sessions   *handler.SessionTracker
+	recorder   *Recorder // 录制收到的原始数据帧，可以为nil
+	clock      Clock
+	dispatched chan struct{} // 当前处理协程退出时关闭
+	connected  bool
+	mutex      sync.RWMutex
+
+	heartbeatInterval time.Duration
+	watchdog          *watchdog
+	disconnectReason  string
+}
+
+// ClientHealth 连接健康状态
+type ClientHealth struct {
+	LastHeartbeatReply time.Time `json:"last_heartbeat_reply"` // 最后一次收到心跳回应的时间
+	LastMessage        time.Time `json:"last_message"`         // 最后一次收到消息的时间
+	DisconnectReason   string    `json:"disconnect_reason"`    // 最近一次断开的原因
 }
 
-func NewDanmuClient(roomID int) *DanmuClient {
+func NewDanmuClient(roomID, shortID int, cfg *config.Config, apiClient *api.Client, bus *event.Bus) *DanmuClient {
 	client := &DanmuClient{
 		roomID:   roomID,
+		shortID:  shortID,
+		protover: cfg.ProtocolVersion,
+		api:      apiClient,
+		hosts: newHostPool(api.DanmuHost{
+			Host:    cfg.DanmuServer,
+			Port:    cfg.DanmuPort,
+			WssPort: 443,
+			WsPort:  2244,
+		}, cfg.GetTransports(roomID)),
+		factory:  NewTransport,
+		clock:    RealClock,
 		done:     make(chan struct{}),
-		handlers: make(map[string]handler.MessageHandler),
+		builtin:  make(map[string]handler.MessageHandler),
+		handlers: handler.NewRegistry(),
+		queue:    newDispatchQueue(cfg.DispatchQueueSize),
+
+		heartbeatInterval: time.Duration(cfg.HeartbeatInterval) * time.Second,
+		watchdog: newWatchdog(
+			time.Duration(cfg.HeartbeatTimeout)*time.Second,
+			time.Duration(cfg.MessageTimeout)*time.Second,
+		),
+	}
+
+	if client.heartbeatInterval <= 0 {
+		client.heartbeatInterval = 30 * time.Second
 	}
 
 	// 注册消息处理器
-	client.registerHandlers()
+	emitter := bus.Emitter(roomID, shortID)
+	client.gifts = handler.NewGiftAggregator(emitter, time.Duration(cfg.GiftComboWindow)*time.Second)
+	client.superChats = handler.NewSuperChatTracker(emitter)
+	client.guards = handler.NewGuardRoster()
+	client.sessions = handler.NewSessionTracker(emitter)
+	client.registerHandlers(emitter)
 
 	return client
 }
 
-func (c *DanmuClient) registerHandlers() {
-	c.handlers[protocol.CmdDanmu] = handler.NewDanmuHandler(c.roomID)
-	c.handlers[protocol.CmdGift] = handler.NewGiftHandler(c.roomID)
-	c.handlers[protocol.CmdWelcome] = handler.NewWelcomeHandler(c.roomID)
-	c.handlers[protocol.CmdFollow] = handler.NewFollowHandler(c.roomID)
+func (c *DanmuClient) registerHandlers(emitter *event.Emitter) {
+	c.builtin[protocol.CmdDanmu] = handler.NewDanmuHandler(emitter)
+	c.builtin[protocol.CmdGift] = handler.NewGiftHandler(emitter, c.gifts, c.api.Gifts())
+	c.builtin[protocol.CmdComboSend] = handler.NewComboSendHandler(c.gifts)
+	c.builtin[protocol.CmdWelcome] = handler.NewWelcomeHandler(emitter)
+	c.guardBuys = handler.NewGuardHandler(emitter, c.guards)
+	c.builtin[protocol.CmdGuardBuy] = c.guardBuys
+	c.builtin[protocol.CmdUserToast] = c.guardBuys
+	c.builtin[protocol.CmdWelcomeGuard] = handler.NewWelcomeGuardHandler(emitter)
+	c.builtin[protocol.CmdSuperChat] = handler.NewSuperChatHandler(emitter, c.superChats)
+	c.builtin[protocol.CmdSuperChatJPN] = handler.NewSuperChatHandler(emitter, c.superChats)
+	c.builtin[protocol.CmdOnlineCount] = handler.NewOnlineCountHandler(emitter)
+
+	c.builtin[protocol.CmdInteractWord] = handler.NewInteractHandler(emitter)
+	c.builtin[protocol.CmdInteractWordV2] = handler.NewInteractHandler(emitter)
+	c.builtin[protocol.CmdLikeClick] = handler.NewLikeHandler(emitter)
+	c.builtin[protocol.CmdLikeUpdate] = handler.NewLikeHandler(emitter)
+	c.builtin[protocol.CmdEntryEffect] = handler.NewEntryEffectHandler(emitter)
+	c.builtin[protocol.CmdWatchedChange] = handler.NewWatchedHandler(emitter)
+	c.builtin[protocol.CmdLive] = handler.NewLiveStatusHandler(emitter, c.sessions)
+	c.builtin[protocol.CmdPreparing] = handler.NewLiveStatusHandler(emitter, c.sessions)
+	c.builtin[protocol.CmdRoomChange] = handler.NewRoomChangeHandler(emitter, c.sessions)
+	c.builtin[protocol.CmdRoomBlock] = handler.NewRoomBlockHandler(emitter)
+	c.builtin[protocol.CmdWarning] = handler.NewWarningHandler(emitter)
+	c.builtin[protocol.CmdCutOff] = handler.NewWarningHandler(emitter)
+	c.builtin[protocol.CmdSuperChatDelete] = handler.NewSuperChatDeleteHandler(emitter, c.superChats)
+	c.builtin[protocol.CmdOnlineRankV2] = handler.NewOnlineRankHandler(emitter)
+	c.builtin[protocol.CmdOnlineRankV3] = handler.NewOnlineRankHandler(emitter)
+	c.builtin[protocol.CmdRecallDanmu] = handler.NewRecallDanmuHandler(emitter)
+	c.builtin[protocol.CmdRedPocketStart] = handler.NewRedPocketHandler(emitter)
+	c.builtin[protocol.CmdRedPocketNew] = handler.NewRedPocketHandler(emitter)
+	c.builtin[protocol.CmdRedPocketWinners] = handler.NewRedPocketHandler(emitter)
+
+	c.fallback = handler.NewRawHandler(emitter)
 }
 
-func (c *DanmuClient) Connect() error {
-	// 构建WebSocket连接URL
-	u := url.URL{
-		Scheme: "wss",
-		Host:   "broadcastlv.chat.bilibili.com:443",
-		Path:   "/sub",
+// Connect 建立连接并完成认证
+// ctx被取消时中断连接过程，连接建立后取消ctx也会关闭连接
+func (c *DanmuClient) Connect(ctx context.Context) error {
+	// 获取认证token
+	info, err := c.api.GetDanmuInfo(ctx, c.roomID)
+	if err != nil {
+		return fmt.Errorf("获取弹幕服务器信息失败: %v", err)
 	}
 
-	// 设置请求头
-	headers := make(map[string][]string)
-	headers["User-Agent"] = []string{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"}
-	headers["Origin"] = []string{"https://live.bilibili.com"}
-
-	// 添加Cookie
-	if cookieStr := auth.GetCookieString(); cookieStr != "" {
-		headers["Cookie"] = []string{cookieStr}
+	buvid, err := c.api.GetBuvid(ctx)
+	if err != nil {
+		return fmt.Errorf("获取buvid失败: %v", err)
 	}
 
-	dialer := websocket.DefaultDialer
-	conn, _, err := dialer.Dial(u.String(), headers)
+	// 按优先级尝试服务器列表
+	c.hosts.update(info.HostList)
+	transport, endpoint, err := c.dialHosts(ctx)
 	if err != nil {
 		return err
 	}
 
-	c.mutex.Lock()
-	c.conn = conn
-	c.connected = true
-	c.done = make(chan struct{})
-	c.mutex.Unlock()
+	c.attach(transport, endpoint)
+	c.watchdog.reset(c.clock.Now())
 
 	// 发送认证包
-	token := auth.GenerateToken(c.roomID)
-	authPacket := protocol.NewAuthPacket(c.roomID, token)
-	err = c.conn.WriteMessage(websocket.BinaryMessage, authPacket.Encode())
+	authPacket := protocol.NewAuthPacket(protocol.AuthParams{
+		RoomID:   c.roomID,
+		UID:      auth.GetUID(),
+		Buvid:    buvid,
+		Token:    info.Token,
+		Protover: c.protover,
+	})
+	err = transport.SendPacket(authPacket)
 	if err != nil {
 		c.Close()
 		return err
 	}
 
+	// ctx取消时关闭连接，中断阻塞中的读取
+	go c.closeOnCancel(ctx, c.done)
+
 	// 启动心跳
-	go c.heartbeat()
+	go c.heartbeat(transport, c.done)
+
+	// 启动看门狗
+	if c.watchdog.heartbeatTimeout > 0 || c.watchdog.messageTimeout > 0 {
+		go c.watch(endpoint, c.done)
+	}
 
 	// 启动消息接收
-	go c.readMessages()
+	go c.readMessages(transport, endpoint, c.done)
 
 	return nil
 }
 
+// Replay 用给定的连接代替弹幕服务器，不获取token，也不发送认证包和心跳
+// 收到的数据照常经过解包、排队和处理器，连接读完后断开，可以通过Done等待
+func (c *DanmuClient) Replay(ctx context.Context, transport Transport) error {
+	endpoint := Endpoint{Scheme: SchemeReplay}
+	if err := transport.Dial(ctx, endpoint, nil); err != nil {
+		return err
+	}
+
+	c.attach(transport, endpoint)
+	go c.closeOnCancel(ctx, c.done)
+	go c.readMessages(transport, endpoint, c.done)
+
+	return nil
+}
+
+// attach 切换到新的连接并启动消息处理
+// 处理协程随连接关闭而退出，之后的认证等步骤失败时也不会让下一次连接一直等待它
+func (c *DanmuClient) attach(transport Transport, endpoint Endpoint) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.transport = transport
+	c.endpoint = endpoint
+	c.connected = true
+	c.done = make(chan struct{})
+	c.disconnectReason = ""
+	previous := c.dispatched
+	c.dispatched = make(chan struct{})
+
+	// 上一次连接遗留的消息处理完后才开始
+	go c.dispatch(c.done, previous, c.dispatched)
+}
+
+// dialHosts 依次尝试各服务器，同一服务器按配置的连接方式顺序尝试
+func (c *DanmuClient) dialHosts(ctx context.Context) (Transport, Endpoint, error) {
+	// 设置请求头
+	headers := make(http.Header)
+	headers.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	headers.Set("Origin", "https://live.bilibili.com")
+
+	// 添加Cookie
+	if cookieStr := auth.GetCookieString(); cookieStr != "" {
+		headers.Set("Cookie", cookieStr)
+	}
+
+	var lastErr error
+	for _, host := range c.hosts.candidates() {
+		for _, endpoint := range c.hosts.endpoints(host) {
+			transport, err := c.factory(endpoint.Scheme)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+
+			start := time.Now()
+			err = transport.Dial(ctx, endpoint, headers)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, Endpoint{}, ctx.Err()
+				}
+				utils.Logger.Warnf("房间 %d 连接 %s 失败: %v", c.roomID, endpoint, err)
+				lastErr = err
+				continue
+			}
+
+			c.hosts.markSuccess(host.Host, time.Since(start))
+			return transport, endpoint, nil
+		}
+
+		c.hosts.markFailure(host.Host)
+	}
+
+	if lastErr == nil {
+		lastErr = errors.New("没有可用的弹幕服务器")
+	}
+	return nil, Endpoint{}, fmt.Errorf("所有弹幕服务器均连接失败: %v", lastErr)
+}
+
+// 获取真实房间号
+func (c *DanmuClient) RoomID() int {
+	return c.roomID
+}
+
+// 获取房间短号，没有短号时为0
+func (c *DanmuClient) ShortID() int {
+	return c.shortID
+}
+
 func (c *DanmuClient) IsConnected() bool {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 	return c.connected
 }
 
-func (c *DanmuClient) heartbeat() {
-	ticker := time.NewTicker(30 * time.Second)
+// 获取当前连接的断开信号，连接断开时关闭
+func (c *DanmuClient) Done() <-chan struct{} {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.done
+}
+
+// 获取当前连接的服务器地址，未连接时返回空字符串
+func (c *DanmuClient) Endpoint() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if !c.connected {
+		return ""
+	}
+	return c.endpoint.String()
+}
+
+// 获取连接健康状态
+func (c *DanmuClient) Health() ClientHealth {
+	lastHeartbeatReply, lastMessage := c.watchdog.times()
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return ClientHealth{
+		LastHeartbeatReply: lastHeartbeatReply,
+		LastMessage:        lastMessage,
+		DisconnectReason:   c.disconnectReason,
+	}
+}
+
+// 获取最近一次断开的原因
+func (c *DanmuClient) DisconnectReason() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.disconnectReason
+}
+
+// closeOnCancel 在ctx取消时关闭连接
+func (c *DanmuClient) closeOnCancel(ctx context.Context, done chan struct{}) {
+	select {
+	case <-ctx.Done():
+		c.Close()
+	case <-done:
+	}
+}
+
+func (c *DanmuClient) heartbeat(transport Transport, done chan struct{}) {
+	ticker := time.NewTicker(c.heartbeatInterval)
 	defer ticker.Stop()
 
 	for {
@@ -107,49 +342,103 @@ func (c *DanmuClient) heartbeat() {
 			}
 
 			heartbeatPacket := protocol.NewHeartbeatPacket()
-			err := c.conn.WriteMessage(websocket.BinaryMessage, heartbeatPacket.Encode())
+			err := transport.SendPacket(heartbeatPacket)
 			if err != nil {
 				utils.Logger.Errorf("房间 %d 发送心跳失败: %v", c.roomID, err)
-				c.Close()
+				c.closeWithReason(fmt.Sprintf("发送心跳失败: %v", err))
 				return
 			}
-		case <-c.done:
+		case <-done:
 			return
 		}
 	}
 }
 
-func (c *DanmuClient) readMessages() {
+// watch 定期检查心跳回应和消息是否超时，超时则断开连接触发重连
+// endpoint是启动时连接的地址，不读取之后可能被下一次连接替换的c.endpoint
+func (c *DanmuClient) watch(endpoint Endpoint, done chan struct{}) {
+	interval := c.watchdog.interval()
+
+	for {
+		select {
+		case now := <-c.clock.After(interval):
+			if reason := c.watchdog.check(now); reason != "" {
+				utils.Logger.Warnf("房间 %d %s，断开重连", c.roomID, reason)
+				c.hosts.markFailure(endpoint.Host)
+				c.closeWithReason(reason)
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// readMessages 读取连接收到的数据帧，endpoint与transport一起由调用方传入
+func (c *DanmuClient) readMessages(transport Transport, endpoint Endpoint, done chan struct{}) {
 	defer c.Close()
 
 	for {
 		select {
-		case <-c.done:
+		case <-done:
 			return
 		default:
-			messageType, data, err := c.conn.ReadMessage()
+			data, err := transport.ReadFrame()
+			if errors.Is(err, ErrReplayFinished) {
+				c.closeWithReason(err.Error())
+				return
+			}
 			if err != nil {
-				utils.Logger.Errorf("房间 %d 读取消息失败: %v", c.roomID, err)
+				// 非主动关闭的断线，下次重连时轮换到其他服务器
+				if c.IsConnected() {
+					utils.Logger.Errorf("房间 %d 读取消息失败: %v", c.roomID, err)
+					c.hosts.markFailure(endpoint.Host)
+					c.closeWithReason(fmt.Sprintf("读取消息失败: %v", err))
+				}
 				return
 			}
 
-			if messageType == websocket.BinaryMessage {
-				c.handleBinaryMessage(data)
-			}
+			c.record(data)
+			c.handleBinaryMessage(data)
 		}
 	}
 }
 
+func (c *DanmuClient) record(data []byte) {
+	c.mutex.RLock()
+	recorder := c.recorder
+	c.mutex.RUnlock()
+
+	if recorder == nil {
+		return
+	}
+	if err := recorder.Record(time.Now(), data); err != nil {
+		utils.Logger.Warnf("房间 %d 录制数据失败: %v", c.roomID, err)
+	}
+}
+
+// SetRecorder 设置录制器，之后收到的每一帧原始数据都会被录制，传nil停止录制
+// 录制器由调用方关闭
+func (c *DanmuClient) SetRecorder(recorder *Recorder) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.recorder = recorder
+}
+
 func (c *DanmuClient) handleBinaryMessage(data []byte) {
-	packet, err := protocol.DecodePacket(data)
+	// 一帧中可能拼接了多个数据包，压缩包会被解压后一并拆出
+	err := protocol.ForEachPacket(data, c.handlePacket)
 	if err != nil {
 		utils.Logger.Errorf("房间 %d 解析数据包失败: %v", c.roomID, err)
-		return
 	}
+}
 
+func (c *DanmuClient) handlePacket(packet *protocol.Packet) {
 	switch packet.Operation {
 	case protocol.OpHeartbeatReply:
 		// 心跳回应，包含在线人数
+		c.watchdog.heartbeatReplied(c.clock.Now())
 		if len(packet.Body) >= 4 {
 			onlineCount := int32(packet.Body[0])<<24 | int32(packet.Body[1])<<16 |
 				int32(packet.Body[2])<<8 | int32(packet.Body[3])
@@ -157,25 +446,174 @@ func (c *DanmuClient) handleBinaryMessage(data []byte) {
 		}
 	case protocol.OpMessage:
 		// 普通消息
-		c.handleMessage(packet.Body)
+		c.watchdog.messageReceived(c.clock.Now())
+		c.enqueueMessage(packet.Body)
 	case protocol.OpConnect:
 		utils.Logger.Infof("房间 %d 连接成功", c.roomID)
 	}
 }
 
-func (c *DanmuClient) handleMessage(data []byte) {
-	cmd, msgData, err := protocol.ParseMessage(data)
+// Handlers 获取本房间的处理器注册表，注册的处理器优先于全局和内置处理器
+func (c *DanmuClient) Handlers() *handler.Registry {
+	return c.handlers
+}
+
+// SetClock 替换看门狗使用的时钟，需要在Connect之前调用，主要用于测试
+func (c *DanmuClient) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// SetGlobalHandlers 设置所有房间共用的处理器注册表，需要在Connect之前调用
+func (c *DanmuClient) SetGlobalHandlers(global *handler.Registry) {
+	c.chains.mutex.Lock()
+	defer c.chains.mutex.Unlock()
+
+	c.global = global
+	c.chains.handlers = nil
+}
+
+// dispatchChains 按命令缓存已用中间件包裹好的处理器，注册表的版本变化后全部作废
+type dispatchChains struct {
+	mutex         sync.Mutex
+	handlers      map[string]handler.MessageHandler
+	version       uint64 // 构建时本房间注册表的版本
+	globalVersion uint64 // 构建时全局注册表的版本
+}
+
+// dispatcher 获取命令的处理器，注册表没有变化时复用已包裹好的处理器
+func (c *DanmuClient) dispatcher(cmd string) handler.MessageHandler {
+	c.chains.mutex.Lock()
+	defer c.chains.mutex.Unlock()
+
+	// 先取版本再构建，构建期间注册表被修改时缓存的是较新的内容，下次会按新版本重建
+	version := c.handlers.Version()
+	var globalVersion uint64
+	if c.global != nil {
+		globalVersion = c.global.Version()
+	}
+
+	if c.chains.handlers == nil || c.chains.version != version || c.chains.globalVersion != globalVersion {
+		c.chains.handlers = make(map[string]handler.MessageHandler)
+		c.chains.version = version
+		c.chains.globalVersion = globalVersion
+	}
+
+	h, ok := c.chains.handlers[cmd]
+	if !ok {
+		h = c.buildDispatcher(cmd)
+		c.chains.handlers[cmd] = h
+	}
+	return h
+}
+
+// buildDispatcher 按本房间、全局、内置的顺序查找处理器，并用全局和本房间的中间件包裹
+func (c *DanmuClient) buildDispatcher(cmd string) handler.MessageHandler {
+	h, ok := c.handlers.Lookup(cmd)
+	if !ok && c.global != nil {
+		h, ok = c.global.Lookup(cmd)
+	}
+	if !ok {
+		h, ok = c.builtin[cmd]
+	}
+	if !ok {
+		h = c.fallback
+	}
+
+	middlewares := []handler.Middleware{handler.Recovery()}
+	if c.global != nil {
+		middlewares = append(middlewares, c.global.Middlewares()...)
+	}
+	middlewares = append(middlewares, c.handlers.Middlewares()...)
+
+	return handler.Chain(h, middlewares...)
+}
+
+// enqueueMessage 解析消息后放入待处理队列，不在读取协程中执行处理器
+func (c *DanmuClient) enqueueMessage(data []byte) {
+	msg, err := protocol.ParseMessage(data)
 	if err != nil {
 		utils.Logger.Errorf("房间 %d 解析消息失败: %v", c.roomID, err)
 		return
 	}
 
-	if handler, exists := c.handlers[cmd]; exists {
-		handler.Handle(msgData)
+	msg.RoomID = c.roomID
+	c.queue.push(msg)
+}
+
+// dispatch 从队列中取出消息交给处理器，等previous结束后才开始，保证同一时间只有一个处理协程
+func (c *DanmuClient) dispatch(done, previous, finished chan struct{}) {
+	defer close(finished)
+
+	if previous != nil {
+		<-previous
 	}
+
+	for {
+		if msg, ok := c.queue.pop(); ok {
+			c.handleMessage(msg)
+			continue
+		}
+
+		select {
+		case <-c.queue.notify:
+		case <-done:
+			// 连接关闭后处理完已排队的消息
+			for {
+				msg, ok := c.queue.pop()
+				if !ok {
+					return
+				}
+				c.handleMessage(msg)
+			}
+		}
+	}
+}
+
+func (c *DanmuClient) handleMessage(msg *protocol.Message) {
+	if err := c.dispatcher(msg.Cmd).Handle(msg); err != nil {
+		utils.Logger.Warnf("房间 %d 处理 %s 消息失败: %v", c.roomID, msg.Cmd, err)
+	}
+}
+
+// FlushGifts 等已排队的消息处理完后立即结算未结束的礼物连击，并发布等待合并的上舰，需要在Close之后调用
+func (c *DanmuClient) FlushGifts() {
+	c.mutex.RLock()
+	dispatched := c.dispatched
+	c.mutex.RUnlock()
+
+	if dispatched != nil {
+		<-dispatched
+	}
+	c.gifts.Flush()
+	c.guardBuys.Flush()
+}
+
+// SuperChats 获取正在展示的醒目留言
+func (c *DanmuClient) SuperChats() []model.SuperChatMessage {
+	return c.superChats.List()
+}
+
+// Guards 获取大航海名单
+func (c *DanmuClient) Guards() *handler.GuardRoster {
+	return c.guards
+}
+
+// Sessions 获取直播场次跟踪器
+func (c *DanmuClient) Sessions() *handler.SessionTracker {
+	return c.sessions
+}
+
+// QueueStats 获取待处理消息队列的状态
+func (c *DanmuClient) QueueStats() QueueStats {
+	return c.queue.stats()
 }
 
 func (c *DanmuClient) Close() {
+	c.closeWithReason(ReasonClosed)
+}
+
+// closeWithReason 关闭连接并记录原因，重复关闭时保留第一次的原因
+func (c *DanmuClient) closeWithReason(reason string) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
@@ -184,9 +622,10 @@ func (c *DanmuClient) Close() {
 	}
 
 	c.connected = false
+	c.disconnectReason = reason
 	close(c.done)
 
-	if c.conn != nil {
-		c.conn.Close()
+	if c.transport != nil {
+		c.transport.Close()
 	}
 }
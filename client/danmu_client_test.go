@@ -0,0 +1,152 @@
+package client
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/config"
+	"TianHe-API/event"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeAPI 启动返回固定数据的假接口服务器
+func newFakeAPI(t *testing.T) *api.Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/x/frontend/finger/spi", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":0,"data":{"b_3":"test-buvid"}}`))
+	})
+	mux.HandleFunc("/x/web-interface/nav", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":-101,"message":"账号未登录","data":{"wbi_img":{"img_url":"https://i0.hdslb.com/bfs/wbi/7cd084941338484aae1ad9425b84077c.png","sub_url":"https://i0.hdslb.com/bfs/wbi/4932caff0ff746eab6f01bf08b70ac45.png"}}}`))
+	})
+	mux.HandleFunc("/xlive/web-room/v1/index/getDanmuInfo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":0,"data":{"token":"test-token","host_list":[{"host":"127.0.0.1","port":2243,"wss_port":443,"ws_port":2244}]}}`))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api.NewClient()
+	client.LiveBaseURL = server.URL
+	client.MainBaseURL = server.URL
+	return client
+}
+
+func TestConnectRecoversAfterAuthFailure(t *testing.T) {
+	bus := event.NewBus()
+	sub := bus.Subscribe(event.Filter{Types: []event.Type{event.TypeDanmu}}, 1)
+	defer sub.Unsubscribe()
+
+	cfg := config.NewConfig()
+	cfg.Transports = []string{SchemeTCP}
+	c := NewDanmuClient(1000, 0, cfg, newFakeAPI(t), bus)
+
+	failing := newFakeTransport()
+	failing.sendErr = errors.New("认证失败")
+	working := newFakeTransport()
+	transports := []*fakeTransport{failing, working}
+	c.factory = func(scheme string) (Transport, error) {
+		transport := transports[0]
+		transports = transports[1:]
+		return transport, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := c.Connect(ctx); err == nil {
+		t.Fatal("认证包发送失败时Connect应返回错误")
+	}
+	if err := c.Connect(ctx); err != nil {
+		t.Fatalf("第二次Connect失败: %v", err)
+	}
+
+	working.frames <- messageFrame(danmuBody)
+	select {
+	case e := <-sub.C:
+		danmu, _ := e.Danmu()
+		if danmu.Text != "你好" {
+			t.Errorf("弹幕内容 = %q", danmu.Text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("认证失败后重新连接收到的消息没有被处理")
+	}
+
+	c.Close()
+	flushed := make(chan struct{})
+	go func() {
+		c.FlushGifts()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("FlushGifts没有返回")
+	}
+}
+
+func TestFlushGiftsPublishesPendingGuard(t *testing.T) {
+	guardBuy := `{"cmd":"GUARD_BUY","data":{"uid":12345678,"username":"测试观众","guard_level":3,"num":1,"price":198000,"gift_name":"舰长"}}`
+
+	// 回放结束后停止房间时，等待与USER_TOAST_MSG合并的GUARD_BUY也要立即发布
+	start := time.Now()
+	events, _ := replay(t, record(t, 0, messageFrame(guardBuy)), ReplayMaxSpeed)
+	if time.Since(start) >= time.Second {
+		t.Errorf("FlushGifts等待了合并窗口")
+	}
+	if len(events) != 1 || events[0].Type != event.TypeGuard {
+		t.Fatalf("事件 = %+v", events)
+	}
+}
+
+func TestWatchdogClosesSilentConnection(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Transports = []string{SchemeTCP}
+	cfg.HeartbeatTimeout = 30
+	cfg.MessageTimeout = 0
+	c := NewDanmuClient(1000, 0, cfg, newFakeAPI(t), event.NewBus())
+
+	clock := newFakeClock()
+	c.SetClock(clock)
+	c.factory = func(scheme string) (Transport, error) {
+		return newFakeTransport(), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	done := c.Done()
+
+	// 连接一直没有数据，每次检查推进一个间隔，直到超过心跳回应超时
+	interval := c.watchdog.interval()
+	for elapsed := time.Duration(0); elapsed <= 30*time.Second; elapsed += interval {
+		if !c.IsConnected() {
+			t.Fatalf("%v 后就断开了连接", elapsed)
+		}
+		clock.waitAfter(t)
+		clock.Advance(interval)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("静默的连接没有被看门狗断开")
+	}
+	if reason := c.DisconnectReason(); !strings.HasPrefix(reason, ReasonHeartbeatTimeout) {
+		t.Errorf("断开原因 = %q", reason)
+	}
+
+	// 断开的服务器在冷却期内排到末尾
+	c.hosts.mutex.Lock()
+	failures := c.hosts.getStats("127.0.0.1").failures
+	c.hosts.mutex.Unlock()
+	if failures != 1 {
+		t.Errorf("服务器失败 %d 次", failures)
+	}
+}
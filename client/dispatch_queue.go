@@ -0,0 +1,141 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"sync"
+)
+
+// QueueStats 待处理消息队列的状态
+type QueueStats struct {
+	Length   int               `json:"length"`            // 当前排队的消息数
+	Capacity int               `json:"capacity"`          // 队列长度上限
+	Dropped  map[string]uint64 `json:"dropped,omitempty"` // 按命令统计的丢弃数
+}
+
+type queuedMessage struct {
+	seq uint64
+	msg *protocol.Message
+}
+
+// dispatchQueue 读取与处理之间的有界队列
+//
+// 平时按到达顺序出队；队列满时先丢弃优先级最低的最旧消息，
+// 优先级不高于PriorityProtected的消息永远不会被丢弃，必要时允许超出上限
+type dispatchQueue struct {
+	mutex    sync.Mutex
+	levels   [protocol.PriorityLowest + 1][]queuedMessage // 按优先级分组的先进先出队列
+	length   int
+	capacity int
+	seq      uint64
+	dropped  map[string]uint64
+	notify   chan struct{} // 有新消息入队时通知处理协程
+}
+
+func newDispatchQueue(capacity int) *dispatchQueue {
+	if capacity <= 0 {
+		capacity = 1024
+	}
+
+	return &dispatchQueue{
+		capacity: capacity,
+		dropped:  make(map[string]uint64),
+		notify:   make(chan struct{}, 1),
+	}
+}
+
+// priority 取消息优先级并限制在队列的分组范围内
+func (q *dispatchQueue) priority(cmd string) int {
+	priority := protocol.GetMessagePriority(cmd)
+	if priority < 0 {
+		return 0
+	}
+	if priority > protocol.PriorityLowest {
+		return protocol.PriorityLowest
+	}
+	return priority
+}
+
+// push 入队，队列满时按优先级丢弃消息
+func (q *dispatchQueue) push(msg *protocol.Message) {
+	priority := q.priority(msg.Cmd)
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.length >= q.capacity && priority > protocol.PriorityProtected {
+		// 从最低优先级开始找可以丢弃的消息，找不到比新消息更不重要的就丢弃新消息
+		victim := -1
+		for level := protocol.PriorityLowest; level >= priority; level-- {
+			if len(q.levels[level]) > 0 {
+				victim = level
+				break
+			}
+		}
+
+		if victim < 0 {
+			q.dropped[msg.Cmd]++
+			return
+		}
+
+		dropped := q.levels[victim][0]
+		q.levels[victim][0] = queuedMessage{}
+		q.levels[victim] = q.levels[victim][1:]
+		q.length--
+		q.dropped[dropped.msg.Cmd]++
+	}
+
+	q.seq++
+	q.levels[priority] = append(q.levels[priority], queuedMessage{seq: q.seq, msg: msg})
+	q.length++
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop 按到达顺序出队
+func (q *dispatchQueue) pop() (*protocol.Message, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.length == 0 {
+		return nil, false
+	}
+
+	// 各分组内部有序，取各组队首中最早到达的
+	next := -1
+	for level := range q.levels {
+		if len(q.levels[level]) == 0 {
+			continue
+		}
+		if next < 0 || q.levels[level][0].seq < q.levels[next][0].seq {
+			next = level
+		}
+	}
+
+	item := q.levels[next][0]
+	q.levels[next][0] = queuedMessage{}
+	q.levels[next] = q.levels[next][1:]
+	q.length--
+
+	return item.msg, true
+}
+
+// stats 获取队列状态
+func (q *dispatchQueue) stats() QueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	stats := QueueStats{
+		Length:   q.length,
+		Capacity: q.capacity,
+	}
+	if len(q.dropped) > 0 {
+		stats.Dropped = make(map[string]uint64, len(q.dropped))
+		for cmd, count := range q.dropped {
+			stats.Dropped[cmd] = count
+		}
+	}
+	return stats
+}
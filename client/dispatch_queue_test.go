@@ -0,0 +1,161 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"fmt"
+	"testing"
+)
+
+// queued 创建一条可以通过Raw区分先后的消息
+func queued(cmd string, n int) *protocol.Message {
+	return &protocol.Message{Cmd: cmd, Raw: []byte(fmt.Sprint(n))}
+}
+
+// drain 取出队列中的全部消息
+func drain(q *dispatchQueue) []*protocol.Message {
+	var messages []*protocol.Message
+	for {
+		msg, ok := q.pop()
+		if !ok {
+			return messages
+		}
+		messages = append(messages, msg)
+	}
+}
+
+func TestDispatchQueueFIFO(t *testing.T) {
+	q := newDispatchQueue(10)
+	cmds := []string{protocol.CmdDanmu, protocol.CmdSuperChat, "UNKNOWN_CMD", protocol.CmdGift, protocol.CmdWatchedChange}
+	for i, cmd := range cmds {
+		q.push(queued(cmd, i))
+	}
+
+	// 不同优先级的消息也按到达顺序出队
+	messages := drain(q)
+	if len(messages) != len(cmds) {
+		t.Fatalf("出队 %d 条", len(messages))
+	}
+	for i, msg := range messages {
+		if string(msg.Raw) != fmt.Sprint(i) {
+			t.Errorf("第%d条出队的是 %s %s", i, msg.Cmd, msg.Raw)
+		}
+	}
+}
+
+func TestDispatchQueueShedsLowestFirst(t *testing.T) {
+	q := newDispatchQueue(8)
+
+	// 优先级 9、7、6、5 各两条
+	levels := []string{"UNKNOWN_CMD", protocol.CmdWatchedChange, protocol.CmdLikeClick, protocol.CmdInteractWord}
+	n := 0
+	for _, cmd := range levels {
+		for i := 0; i < 2; i++ {
+			q.push(queued(cmd, n))
+			n++
+		}
+	}
+
+	// 八条弹幕把低优先级的消息全部挤掉
+	for i := 0; i < 8; i++ {
+		q.push(queued(protocol.CmdDanmu, 100+i))
+	}
+
+	stats := q.stats()
+	if stats.Length != 8 {
+		t.Errorf("队列长度 = %d", stats.Length)
+	}
+	for _, cmd := range levels {
+		if stats.Dropped[cmd] != 2 {
+			t.Errorf("%s 丢弃 %d 条, want 2", cmd, stats.Dropped[cmd])
+		}
+	}
+	if stats.Dropped[protocol.CmdDanmu] != 0 {
+		t.Errorf("丢弃了 %d 条弹幕", stats.Dropped[protocol.CmdDanmu])
+	}
+
+	for i, msg := range drain(q) {
+		if msg.Cmd != protocol.CmdDanmu || string(msg.Raw) != fmt.Sprint(100+i) {
+			t.Errorf("第%d条剩下的是 %s %s", i, msg.Cmd, msg.Raw)
+		}
+	}
+}
+
+func TestDispatchQueueShedOrder(t *testing.T) {
+	q := newDispatchQueue(8)
+	levels := []string{protocol.CmdInteractWord, protocol.CmdLikeClick, protocol.CmdWatchedChange, "UNKNOWN_CMD"}
+	n := 0
+	for _, cmd := range levels {
+		for i := 0; i < 2; i++ {
+			q.push(queued(cmd, n))
+			n++
+		}
+	}
+
+	// 逐条挤入弹幕，记录每次被丢弃的命令
+	var order []string
+	before := map[string]uint64{}
+	for i := 0; i < 8; i++ {
+		q.push(queued(protocol.CmdDanmu, 100+i))
+		for cmd, count := range q.stats().Dropped {
+			if count > before[cmd] {
+				order = append(order, cmd)
+				before[cmd] = count
+			}
+		}
+	}
+
+	want := []string{"UNKNOWN_CMD", "UNKNOWN_CMD", protocol.CmdWatchedChange, protocol.CmdWatchedChange,
+		protocol.CmdLikeClick, protocol.CmdLikeClick, protocol.CmdInteractWord, protocol.CmdInteractWord}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("丢弃顺序 = %v, want %v", order, want)
+	}
+}
+
+func TestDispatchQueueDropsOldestWithinLevel(t *testing.T) {
+	q := newDispatchQueue(3)
+	for i := 0; i < 3; i++ {
+		q.push(queued(protocol.CmdInteractWord, i))
+	}
+	q.push(queued(protocol.CmdInteractWord, 3))
+
+	messages := drain(q)
+	if len(messages) != 3 || string(messages[0].Raw) != "1" || string(messages[2].Raw) != "3" {
+		t.Errorf("同一优先级应丢弃最旧的: %v", messages)
+	}
+}
+
+func TestDispatchQueueNeverDropsProtected(t *testing.T) {
+	q := newDispatchQueue(4)
+	for i := 0; i < 4; i++ {
+		q.push(queued(protocol.CmdDanmu, i))
+	}
+
+	// 队列满且没有更低优先级的消息时，新来的普通消息被丢弃
+	q.push(queued(protocol.CmdDanmu, 4))
+	q.push(queued(protocol.CmdInteractWord, 5))
+
+	// 醒目留言和上舰允许超出上限
+	protected := []string{protocol.CmdSuperChat, protocol.CmdSuperChatJPN, protocol.CmdGuardBuy, protocol.CmdUserToast, protocol.CmdLive}
+	for i, cmd := range protected {
+		q.push(queued(cmd, 10+i))
+	}
+
+	stats := q.stats()
+	if stats.Length != 4+len(protected) {
+		t.Errorf("队列长度 = %d", stats.Length)
+	}
+	want := map[string]uint64{protocol.CmdDanmu: 1, protocol.CmdInteractWord: 1}
+	if fmt.Sprint(stats.Dropped) != fmt.Sprint(want) {
+		t.Errorf("丢弃统计 = %v, want %v", stats.Dropped, want)
+	}
+
+	kept := map[string]bool{}
+	for _, msg := range drain(q) {
+		kept[msg.Cmd] = true
+	}
+	for _, cmd := range protected {
+		if !kept[cmd] {
+			t.Errorf("%s 被丢弃", cmd)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/config"
+	"TianHe-API/event"
+	"TianHe-API/handler"
+	"TianHe-API/protocol"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// newDispatchClient 创建不连接服务器的客户端，返回收集到的事件
+func newDispatchClient(t *testing.T) (*DanmuClient, func() []*event.Event) {
+	t.Helper()
+
+	bus := event.NewBus()
+	var mutex sync.Mutex
+	var events []*event.Event
+	bus.SubscribeFunc(event.Filter{}, func(e *event.Event) {
+		mutex.Lock()
+		events = append(events, e)
+		mutex.Unlock()
+	})
+
+	c := NewDanmuClient(21452505, 0, config.NewConfig(), api.NewClient(), bus)
+	return c, func() []*event.Event {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]*event.Event(nil), events...)
+	}
+}
+
+// dispatchBody 解析消息并交给客户端处理
+func dispatchBody(t *testing.T, c *DanmuClient, body string) {
+	t.Helper()
+
+	msg, err := protocol.ParseMessage([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.handleMessage(msg)
+}
+
+func TestNoticeIsRawPassthrough(t *testing.T) {
+	c, events := newDispatchClient(t)
+
+	// 全站广播的NOTICE_MSG也带msg_type=2，不能当作关注
+	dispatchBody(t, c, `{"cmd":"NOTICE_MSG","id":2,"name":"分区道具抽奖广播样式","msg_type":2,"msg_self":"恭喜 <%测试观众%> 获得大奖","roomid":0,"real_roomid":0}`)
+	// 关注只来自INTERACT_WORD
+	dispatchBody(t, c, `{"cmd":"INTERACT_WORD","data":{"uid":12345678,"uname":"测试观众","msg_type":2,"timestamp":1700000000}}`)
+
+	got := events()
+	if len(got) != 2 || got[0].Type != event.TypeUnknown || got[1].Type != event.TypeFollow {
+		t.Fatalf("事件 = %+v", got)
+	}
+	raw, _ := got[0].Raw()
+	if raw.Cmd != protocol.CmdFollow {
+		t.Errorf("原始消息命令 = %q", raw.Cmd)
+	}
+	follow, _ := got[1].Follow()
+	if follow.UserID != 12345678 {
+		t.Errorf("关注 = %+v", follow)
+	}
+}
+
+func TestDispatcherReusesChain(t *testing.T) {
+	c, _ := newDispatchClient(t)
+	global := handler.NewRegistry()
+	c.SetGlobalHandlers(global)
+
+	// 统计中间件被用来包裹处理器的次数
+	built := 0
+	counting := func(next handler.MessageHandler) handler.MessageHandler {
+		built++
+		return next
+	}
+	c.Handlers().Use(counting)
+
+	for i := 0; i < 3; i++ {
+		dispatchBody(t, c, danmuBody)
+	}
+	if built != 1 {
+		t.Fatalf("处理3条消息包裹了 %d 次", built)
+	}
+
+	// 注册表变化后重新包裹，新的处理器和中间件生效
+	var handled []string
+	global.HandleFunc(protocol.CmdDanmu, func(msg *protocol.Message) error {
+		handled = append(handled, "global")
+		return nil
+	})
+	dispatchBody(t, c, danmuBody)
+	c.Handlers().HandleFunc(protocol.CmdDanmu, func(msg *protocol.Message) error {
+		handled = append(handled, "room")
+		return nil
+	})
+	dispatchBody(t, c, danmuBody)
+	dispatchBody(t, c, danmuBody)
+
+	if built != 3 {
+		t.Errorf("注册表变化两次后共包裹了 %d 次", built)
+	}
+	if fmt.Sprint(handled) != "[global room room]" {
+		t.Errorf("处理器 = %v", handled)
+	}
+}
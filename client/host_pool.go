@@ -0,0 +1,175 @@
+package client
+
+import (
+	"TianHe-API/api"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 连接方式
+const (
+	SchemeWSS = "wss"
+	SchemeWS  = "ws"
+	SchemeTCP = "tcp"
+)
+
+// 服务器失败后的冷却时间，冷却期内排在候选列表末尾
+const hostFailureCooldown = 5 * time.Minute
+
+// Endpoint 一个可拨号的弹幕服务器地址
+type Endpoint struct {
+	Scheme string
+	Host   string
+	Port   int
+}
+
+// Address 返回host:port形式的地址
+func (e Endpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+func (e Endpoint) String() string {
+	return fmt.Sprintf("%s://%s", e.Scheme, e.Address())
+}
+
+// hostStats 单个服务器的连接记录
+type hostStats struct {
+	failures    int
+	lastFailure time.Time
+	latency     time.Duration // 最近一次成功连接的耗时，0表示未知
+}
+
+// hostPool 弹幕服务器列表，按失败记录和连接耗时排序
+type hostPool struct {
+	mutex    sync.Mutex
+	hosts    []api.DanmuHost
+	fallback api.DanmuHost
+	schemes  []string
+	stats    map[string]*hostStats
+}
+
+func newHostPool(fallback api.DanmuHost, schemes []string) *hostPool {
+	return &hostPool{
+		fallback: fallback,
+		schemes:  schemes,
+		stats:    make(map[string]*hostStats),
+	}
+}
+
+// update 更新服务器列表，配置中的默认服务器始终作为最后的备选
+func (p *hostPool) update(hosts []api.DanmuHost) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.hosts = p.hosts[:0]
+	hasFallback := false
+	for _, host := range hosts {
+		if host.Host == "" {
+			continue
+		}
+		if host.Host == p.fallback.Host {
+			hasFallback = true
+		}
+		p.hosts = append(p.hosts, host)
+	}
+
+	if !hasFallback && p.fallback.Host != "" {
+		p.hosts = append(p.hosts, p.fallback)
+	}
+}
+
+// candidates 返回按优先级排序的服务器：
+// 冷却期内失败过的排在最后，其余按已知连接耗时从低到高，未知耗时的保持原顺序
+func (p *hostPool) candidates() []api.DanmuHost {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	now := time.Now()
+	hosts := make([]api.DanmuHost, len(p.hosts))
+	copy(hosts, p.hosts)
+
+	failed := func(host string) bool {
+		stats, ok := p.stats[host]
+		return ok && !stats.lastFailure.IsZero() && now.Sub(stats.lastFailure) < hostFailureCooldown
+	}
+	latency := func(host string) time.Duration {
+		if stats, ok := p.stats[host]; ok {
+			return stats.latency
+		}
+		return 0
+	}
+
+	sort.SliceStable(hosts, func(i, j int) bool {
+		fi, fj := failed(hosts[i].Host), failed(hosts[j].Host)
+		if fi != fj {
+			return !fi
+		}
+		if fi {
+			return p.stats[hosts[i].Host].lastFailure.Before(p.stats[hosts[j].Host].lastFailure)
+		}
+
+		li, lj := latency(hosts[i].Host), latency(hosts[j].Host)
+		if li == 0 || lj == 0 {
+			return li != 0 && lj == 0
+		}
+		return li < lj
+	})
+
+	return hosts
+}
+
+// endpoints 返回一个服务器按连接方式顺序展开的地址
+func (p *hostPool) endpoints(host api.DanmuHost) []Endpoint {
+	var endpoints []Endpoint
+	for _, scheme := range p.schemes {
+		port := 0
+		switch scheme {
+		case SchemeWSS:
+			port = host.WssPort
+		case SchemeWS:
+			port = host.WsPort
+		case SchemeTCP:
+			port = host.Port
+		}
+
+		if port > 0 {
+			endpoints = append(endpoints, Endpoint{Scheme: scheme, Host: host.Host, Port: port})
+		}
+	}
+
+	return endpoints
+}
+
+// markSuccess 记录连接成功及耗时
+func (p *hostPool) markSuccess(host string, latency time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := p.getStats(host)
+	stats.failures = 0
+	stats.lastFailure = time.Time{}
+	stats.latency = latency
+}
+
+// markFailure 记录连接失败，下次连接时该服务器会被轮换到末尾
+func (p *hostPool) markFailure(host string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	stats := p.getStats(host)
+	stats.failures++
+	stats.lastFailure = time.Now()
+}
+
+func (p *hostPool) getStats(host string) *hostStats {
+	stats, ok := p.stats[host]
+	if !ok {
+		stats = &hostStats{}
+		p.stats[host] = stats
+	}
+	return stats
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"TianHe-API/utils"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
+
+// fakeTransport 内存中的连接，frames中的数据依次由ReadFrame返回
+type fakeTransport struct {
+	sendErr error // 不为nil时所有发送都失败
+
+	frames    chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mutex sync.Mutex
+	sent  []*protocol.Packet
+}
+
+func newFakeTransport() *fakeTransport {
+	return &fakeTransport{
+		frames: make(chan []byte, 16),
+		closed: make(chan struct{}),
+	}
+}
+
+func (t *fakeTransport) Dial(ctx context.Context, endpoint Endpoint, header http.Header) error {
+	return ctx.Err()
+}
+
+func (t *fakeTransport) SendPacket(packet *protocol.Packet) error {
+	if t.sendErr != nil {
+		return t.sendErr
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sent = append(t.sent, packet)
+	return nil
+}
+
+func (t *fakeTransport) ReadFrame() ([]byte, error) {
+	select {
+	case frame := <-t.frames:
+		return frame, nil
+	case <-t.closed:
+		return nil, errors.New("连接已关闭")
+	}
+}
+
+func (t *fakeTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return nil
+}
+
+// messageFrame 把一条JSON消息编码为未压缩的数据包
+func messageFrame(body string) []byte {
+	packet := &protocol.Packet{
+		PacketLength: int32(protocol.HeaderLength + len(body)),
+		HeaderLength: protocol.HeaderLength,
+		Version:      protocol.ProtoVerPlain,
+		Operation:    protocol.OpMessage,
+		Body:         []byte(body),
+	}
+	return packet.Encode()
+}
+
+const danmuBody = `{"cmd":"DANMU_MSG","info":[[0,1,25,16777215,1700000000000,0,0,"",0,0,0,"",0,"{}","{}",{"extra":"{}"}],"你好",[123,"测试用户",0,0,0,10000,1,""],[],[10,0,0,">50000",0],[],0,0,null,{"ts":1700000000,"ct":""},0,0,null,null,0,0]}`
+
+// fakeClock 手动推进的时钟
+type fakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	c.fire()
+	return ch
+}
+
+// Advance 推进时间并触发到期的After
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// waitAfter 等待有协程调用After，用于在推进时间前确认对方已经开始等待
+func (c *fakeClock) waitAfter(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		c.mutex.Lock()
+		waiting := len(c.waiters)
+		c.mutex.Unlock()
+		if waiting > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("没有协程在等待假时钟")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func (c *fakeClock) fire() {
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if c.now.Before(w.at) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+}
@@ -1,126 +1,579 @@
 package client
 
 import (
+	"TianHe-API/api"
+	"TianHe-API/auth"
 	"TianHe-API/config"
+	"TianHe-API/event"
+	"TianHe-API/handler"
+	"TianHe-API/model"
 	"TianHe-API/utils"
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sync"
 	"time"
 )
 
+var (
+	// ErrRoomExists 房间已在监听列表中
+	ErrRoomExists = errors.New("房间已存在")
+	// ErrRoomNotFound 房间不在监听列表中
+	ErrRoomNotFound = errors.New("房间不存在")
+)
+
 type Manager struct {
-	clients map[int]*DanmuClient
-	config  *config.Config
-	mutex   sync.RWMutex
-	running bool
-	wg      sync.WaitGroup
+	rooms    map[int]*room
+	aliases  map[int]*api.RoomInit // 短号或真实房间号到房间信息的缓存
+	config   *config.Config
+	api      *api.Client
+	bus      *event.Bus
+	handlers *handler.Registry // 所有房间共用的处理器和中间件
+	clock    Clock
+	mutex    sync.RWMutex
+	running  bool
+	ctx      context.Context // Start传入的ctx，各房间的ctx由它派生
+	wg       sync.WaitGroup
+}
+
+// room 单个房间的客户端与运行状态
+type room struct {
+	shortID   int
+	anchorUID int64 // 主播UID，获取大航海名单时需要
+	client    *DanmuClient
+	backoff   *Backoff
+	cancel    context.CancelFunc // 停止该房间的重连循环，未启动时为nil
+	exited    chan struct{}      // 重连循环退出时关闭
+	recorder  *Recorder          // 原始数据帧录制，未开启时为nil
 }
 
 func NewManager(cfg *config.Config) *Manager {
+	apiClient := api.NewClient()
+	apiClient.Cookie = auth.GetCookieString
+
 	return &Manager{
-		clients: make(map[int]*DanmuClient),
-		config:  cfg,
+		rooms:    make(map[int]*room),
+		aliases:  make(map[int]*api.RoomInit),
+		config:   cfg,
+		api:      apiClient,
+		bus:      event.NewBus(),
+		handlers: handler.NewRegistry(),
+		clock:    RealClock,
 	}
 }
 
-// 添加房间
-func (m *Manager) AddRoom(roomID int) error {
+// 替换接口客户端，需在添加房间前调用
+func (m *Manager) SetAPIClient(apiClient *api.Client) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if _, exists := m.clients[roomID]; exists {
-		return fmt.Errorf("房间 %d 已存在", roomID)
+	m.api = apiClient
+}
+
+// 获取事件总线，所有房间的事件都发布到这里
+func (m *Manager) Bus() *event.Bus {
+	return m.bus
+}
+
+// 订阅事件，buffer为通道缓冲大小
+func (m *Manager) Subscribe(filter event.Filter, buffer int) *event.Subscription {
+	return m.bus.Subscribe(filter, buffer)
+}
+
+// 以回调订阅事件
+func (m *Manager) SubscribeFunc(filter event.Filter, fn func(*event.Event)) *event.Subscription {
+	return m.bus.SubscribeFunc(filter, fn)
+}
+
+// 获取所有房间共用的处理器注册表，注册的处理器会取代内置处理器，中间件作用于所有房间
+func (m *Manager) Handlers() *handler.Registry {
+	return m.handlers
+}
+
+// 获取单个房间的处理器注册表，id可以是短号或真实房间号，优先级高于全局注册表
+func (m *Manager) RoomHandlers(id int) (*handler.Registry, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r, err := m.lookupRoom(id)
+	if err != nil {
+		return nil, err
 	}
+	return r.client.Handlers(), nil
+}
 
-	client := NewDanmuClient(roomID)
-	m.clients[roomID] = client
+// 按短号或真实房间号查找房间，调用时需持有锁
+func (m *Manager) lookupRoom(id int) (*room, error) {
+	roomID := id
+	if info, ok := m.aliases[id]; ok {
+		roomID = info.RoomID
+	}
 
-	return nil
+	r, exists := m.rooms[roomID]
+	if !exists {
+		return nil, fmt.Errorf("房间 %d: %w", id, ErrRoomNotFound)
+	}
+	return r, nil
+}
+
+// 获取房间中正在展示的醒目留言，id可以是短号或真实房间号
+func (m *Manager) SuperChats(id int) ([]model.SuperChatMessage, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r, err := m.lookupRoom(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.SuperChats(), nil
+}
+
+// 获取房间的大航海名单，id可以是短号或真实房间号
+func (m *Manager) Guards(id int) ([]model.GuardMember, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r, err := m.lookupRoom(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.Guards().List(), nil
+}
+
+// 获取房间当前的直播场次，未开播时返回nil，id可以是短号或真实房间号
+func (m *Manager) Session(id int) (*model.LiveSession, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	r, err := m.lookupRoom(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.Sessions().Current(), nil
 }
 
-// 移除房间
-func (m *Manager) RemoveRoom(roomID int) {
+// 添加房间，id可以是短号或真实房间号，管理器运行中时立即开始连接
+// 同一房间的短号和真实房间号只会添加一次，重复添加返回ErrRoomExists
+func (m *Manager) AddRoom(ctx context.Context, id int) error {
+	info, err := m.resolveRoom(ctx, id)
+	if err != nil {
+		return fmt.Errorf("解析房间号 %d 失败: %v", id, err)
+	}
+	roomID := info.RoomID
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if client, exists := m.clients[roomID]; exists {
-		client.Close()
-		delete(m.clients, roomID)
-		utils.Logger.Infof("移除房间 %d", roomID)
+	if _, exists := m.rooms[roomID]; exists {
+		return fmt.Errorf("房间 %d: %w", roomID, ErrRoomExists)
+	}
+
+	r := &room{
+		shortID:   info.ShortID,
+		anchorUID: info.UID,
+		client:    NewDanmuClient(roomID, info.ShortID, m.config, m.api, m.bus),
+		backoff:   NewBackoff(NewBackoffPolicy(m.config), m.clock),
 	}
+	r.client.SetGlobalHandlers(m.handlers)
+	r.client.SetClock(m.clock)
+	m.startRecording(roomID, r)
+	m.rooms[roomID] = r
+
+	if m.running {
+		m.startRoom(roomID, r)
+	}
+
+	return nil
 }
 
-// 启动所有客户端
-func (m *Manager) Start() {
+// resolveRoom 将短号解析为真实房间号，结果会被缓存
+func (m *Manager) resolveRoom(ctx context.Context, id int) (*api.RoomInit, error) {
+	m.mutex.RLock()
+	info, ok := m.aliases[id]
+	apiClient := m.api
+	m.mutex.RUnlock()
+	if ok {
+		return info, nil
+	}
+
+	info, err := apiClient.GetRoomInit(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.ShortID != 0 && info.ShortID != info.RoomID {
+		utils.Logger.Infof("房间短号 %d 对应真实房间号 %d", info.ShortID, info.RoomID)
+	}
+
+	m.mutex.Lock()
+	m.aliases[id] = info
+	m.aliases[info.RoomID] = info
+	if info.ShortID != 0 {
+		m.aliases[info.ShortID] = info
+	}
+	m.mutex.Unlock()
+
+	return info, nil
+}
+
+// 移除房间，id可以是短号或真实房间号，等待该房间的重连循环退出后返回
+func (m *Manager) RemoveRoom(id int) {
+	m.mutex.Lock()
+	roomID := id
+	if info, ok := m.aliases[id]; ok {
+		roomID = info.RoomID
+	}
+	r, exists := m.rooms[roomID]
+	if !exists {
+		m.mutex.Unlock()
+		return
+	}
+	delete(m.rooms, roomID)
+	m.mutex.Unlock()
+
+	m.stopRoom(r)
+	utils.Logger.Infof("移除房间 %d", roomID)
+}
+
+// 启动所有客户端，ctx取消时所有房间停止重连并断开
+func (m *Manager) Start(ctx context.Context) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	m.running = true
+	m.ctx = ctx
+
+	go m.loadGiftConfig(ctx, m.api)
 
-	for roomID, client := range m.clients {
-		m.wg.Add(1)
-		go m.startClient(roomID, client)
+	for roomID, r := range m.rooms {
+		m.startRoom(roomID, r)
 	}
 }
 
-// 启动单个客户端
-func (m *Manager) startClient(roomID int, client *DanmuClient) {
-	defer m.wg.Done()
+// 加载礼物配置：先读本地缓存文件，再从接口刷新，刷新成功后写回缓存文件
+func (m *Manager) loadGiftConfig(ctx context.Context, apiClient *api.Client) {
+	gifts := apiClient.Gifts()
+	path := m.config.GiftConfigPath
+
+	if path != "" && utils.FileExists(path) {
+		if err := gifts.LoadFile(path); err != nil {
+			utils.Logger.Warnf("加载礼物配置文件失败: %v", err)
+		}
+	}
+
+	if err := gifts.Refresh(ctx, apiClient, 0); err != nil {
+		utils.Logger.Warnf("获取礼物配置失败，礼物价格将只使用消息中的数据: %v", err)
+		return
+	}
+	utils.Logger.Infof("已加载 %d 个礼物配置", gifts.Len())
+
+	if path != "" {
+		if err := gifts.SaveFile(path); err != nil {
+			utils.Logger.Warnf("保存礼物配置文件失败: %v", err)
+		}
+	}
+}
+
+// running 房间的重连循环是否在运行，放弃重连后也视为停止
+func (r *room) running() bool {
+	if r.exited == nil {
+		return false
+	}
+
+	select {
+	case <-r.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// startRoom 启动房间的重连循环，调用方需持有锁
+func (m *Manager) startRoom(roomID int, r *room) {
+	if r.running() {
+		return
+	}
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	r.cancel = cancel
+	r.exited = make(chan struct{})
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(r.exited)
+
+		// 重连循环因重试次数用尽退出时也要停止拉取
+		pollCtx, stopPoll := context.WithCancel(ctx)
+		polled := make(chan struct{})
+		go func() {
+			defer close(polled)
+			m.pollRoomInfo(pollCtx, roomID, r.client)
+		}()
+
+		// 大航海名单与连接同时加载，接口慢时不推迟连接
+		seeded := make(chan struct{})
+		go func() {
+			defer close(seeded)
+			m.seedGuards(pollCtx, roomID, r)
+		}()
+
+		m.startClient(ctx, roomID, r.client, r.backoff)
+
+		stopPoll()
+		<-polled
+		<-seeded
+	}()
+}
+
+// 从接口获取大航海名单，失败时名单只由之后的开通事件维持
+func (m *Manager) seedGuards(ctx context.Context, roomID int, r *room) {
+	m.mutex.RLock()
+	apiClient := m.api
+	m.mutex.RUnlock()
+
+	guards, err := apiClient.GetGuardList(ctx, roomID, r.anchorUID)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		utils.Logger.Warnf("房间 %d 获取大航海名单失败: %v", roomID, err)
+		return
+	}
+
+	r.client.Guards().Seed(guards)
+	utils.Logger.Infof("房间 %d 已加载 %d 名大航海成员", roomID, len(guards))
+}
+
+// 定时拉取房间信息，补上连接前已开播或断线期间漏掉的开播、下播
+func (m *Manager) pollRoomInfo(ctx context.Context, roomID int, client *DanmuClient) {
+	interval := time.Duration(m.config.RoomInfoInterval) * time.Second
+	if interval <= 0 {
+		return
+	}
+
+	m.mutex.RLock()
+	apiClient := m.api
+	m.mutex.RUnlock()
+
+	for {
+		info, err := apiClient.GetRoomInfo(ctx, roomID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			utils.Logger.Warnf("房间 %d 获取房间信息失败: %v", roomID, err)
+		} else {
+			client.Sessions().Observe(info)
+		}
+
+		select {
+		case <-m.clock.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stopRoom 取消房间的重连循环并关闭连接，等待循环退出
+func (m *Manager) stopRoom(r *room) {
+	m.mutex.Lock()
+	cancel, exited := r.cancel, r.exited
+	r.cancel = nil
+	m.mutex.Unlock()
+
+	if cancel == nil {
+		r.client.Close()
+		r.client.FlushGifts()
+		r.stopRecording()
+		return
+	}
+
+	cancel()
+	r.client.Close()
+	<-exited
+	r.client.FlushGifts()
+	r.stopRecording()
+}
+
+// 配置了录制目录时为房间创建录制文件，失败时不录制
+func (m *Manager) startRecording(roomID int, r *room) {
+	if m.config.RecordDir == "" {
+		return
+	}
+
+	name := fmt.Sprintf("%d-%s.rec", roomID, time.Now().Format("20060102-150405"))
+	recorder, err := CreateRecorder(filepath.Join(m.config.RecordDir, name), roomID)
+	if err != nil {
+		utils.Logger.Warnf("房间 %d 创建录制文件失败: %v", roomID, err)
+		return
+	}
+
+	r.recorder = recorder
+	r.client.SetRecorder(recorder)
+}
+
+// 停止录制，需在客户端关闭后调用
+func (r *room) stopRecording() {
+	if r.recorder == nil {
+		return
+	}
 
-	retries := 0
-	for m.running && retries < m.config.MaxRetries {
-		err := client.Connect()
+	r.client.SetRecorder(nil)
+	if err := r.recorder.Close(); err != nil {
+		utils.Logger.Warnf("关闭录制文件失败: %v", err)
+	}
+}
+
+// 启动单个客户端
+func (m *Manager) startClient(ctx context.Context, roomID int, client *DanmuClient, backoff *Backoff) {
+	for ctx.Err() == nil {
+		err := client.Connect(ctx)
 		if err != nil {
 			utils.Logger.Errorf("房间 %d 连接失败: %v", roomID, err)
-			retries++
-			if retries < m.config.MaxRetries {
-				utils.Logger.Infof("房间 %d 将在 %d 秒后重试 (%d/%d)",
-					roomID, m.config.RetryDelay, retries, m.config.MaxRetries)
-				time.Sleep(time.Duration(m.config.RetryDelay) * time.Second)
+			if !m.waitRetry(ctx, roomID, backoff, backoff.Failure) {
+				return
 			}
 			continue
 		}
 
+		// 连接期间房间已被移除
+		if ctx.Err() != nil {
+			client.Close()
+			return
+		}
+
 		utils.Logger.Infof("房间 %d 连接成功", roomID)
-		retries = 0
+		backoff.Connected()
 
-		// 等待连接断开
-		<-client.done
+		// 等待连接断开或房间被移除
+		select {
+		case <-client.Done():
+		case <-ctx.Done():
+			client.Close()
+			return
+		}
 
-		if m.running {
-			utils.Logger.Warnf("房间 %d 连接断开，准备重连", roomID)
-			time.Sleep(time.Duration(m.config.RetryDelay) * time.Second)
+		utils.Logger.Warnf("房间 %d 连接断开(%s)，准备重连", roomID, client.DisconnectReason())
+		if !m.waitRetry(ctx, roomID, backoff, backoff.Disconnected) {
+			return
 		}
 	}
+}
 
-	if retries >= m.config.MaxRetries {
+// waitRetry 按退避策略等待下一次重试，返回false表示应停止重连
+func (m *Manager) waitRetry(ctx context.Context, roomID int, backoff *Backoff, next func() (time.Duration, bool)) bool {
+	delay, giveUp := next()
+	if giveUp {
 		utils.Logger.Errorf("房间 %d 重试次数已达上限，停止连接", roomID)
+		return false
 	}
+
+	status := backoff.Status()
+	if status.Breaker == BreakerOpen {
+		utils.Logger.Warnf("房间 %d 连续失败 %d 次，熔断 %v 后再试", roomID, status.Failures, delay)
+	} else if m.config.MaxRetries > 0 {
+		utils.Logger.Infof("房间 %d 将在 %v 后重试 (%d/%d)",
+			roomID, delay.Round(time.Millisecond), status.Failures, m.config.MaxRetries)
+	} else {
+		utils.Logger.Infof("房间 %d 将在 %v 后重试 (第%d次)", roomID, delay.Round(time.Millisecond), status.Failures)
+	}
+
+	select {
+	case <-m.clock.After(delay):
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// 替换时钟，需在添加房间前调用，主要用于测试
+func (m *Manager) SetClock(clock Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.clock = clock
 }
 
-// 停止所有客户端
-func (m *Manager) Stop() {
+// 停止所有客户端，等待各房间退出，ctx到期时不再等待并返回ctx的错误
+func (m *Manager) Stop(ctx context.Context) error {
 	m.mutex.Lock()
 	m.running = false
+	rooms := make(map[int]*room, len(m.rooms))
+	for roomID, r := range m.rooms {
+		rooms[roomID] = r
+	}
+	m.mutex.Unlock()
 
-	for roomID, client := range m.clients {
-		client.Close()
+	// 先取消所有房间，再统一等待，避免逐个等待时超出期限
+	for roomID, r := range rooms {
+		m.mutex.Lock()
+		cancel := r.cancel
+		r.cancel = nil
+		m.mutex.Unlock()
+
+		if cancel != nil {
+			cancel()
+		}
+		r.client.Close()
 		utils.Logger.Infof("关闭房间 %d", roomID)
 	}
-	m.mutex.Unlock()
 
-	m.wg.Wait()
-	utils.Logger.Info("所有客户端已关闭")
+	stopped := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		for _, r := range rooms {
+			r.client.FlushGifts()
+			r.stopRecording()
+		}
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		utils.Logger.Info("所有客户端已关闭")
+		return nil
+	case <-ctx.Done():
+		utils.Logger.Warn("等待客户端关闭超时")
+		return ctx.Err()
+	}
+}
+
+// RoomStatus 房间运行状态
+type RoomStatus struct {
+	RoomID    int           `json:"room_id"`  // 真实房间号
+	ShortID   int           `json:"short_id"` // 短号，没有短号时为0
+	Running   bool          `json:"running"`  // 重连循环是否在运行
+	Connected bool          `json:"connected"`
+	Endpoint  string        `json:"endpoint"`             // 当前连接的弹幕服务器，未连接时为空
+	Backoff   BackoffStatus `json:"backoff"`              // 重连与熔断状态
+	Health    ClientHealth  `json:"health"`               // 心跳与消息的到达情况
+	Queue     QueueStats    `json:"queue"`                // 待处理消息队列与丢弃统计
+	SessionID string        `json:"session_id,omitempty"` // 当前直播场次，未开播时为空
 }
 
 // 获取运行状态
-func (m *Manager) GetStatus() map[int]bool {
+func (m *Manager) GetStatus() map[int]RoomStatus {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	status := make(map[int]bool)
-	for roomID, client := range m.clients {
-		status[roomID] = client.IsConnected()
+	status := make(map[int]RoomStatus)
+	for roomID, r := range m.rooms {
+		status[roomID] = RoomStatus{
+			RoomID:    roomID,
+			ShortID:   r.shortID,
+			Running:   r.running(),
+			Connected: r.client.IsConnected(),
+			Endpoint:  r.client.Endpoint(),
+			Backoff:   r.backoff.Status(),
+			Health:    r.client.Health(),
+			Queue:     r.client.QueueStats(),
+			SessionID: r.client.Sessions().ID(),
+		}
 	}
 
 	return status
@@ -131,8 +584,8 @@ func (m *Manager) GetRooms() []int {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	rooms := make([]int, 0, len(m.clients))
-	for roomID := range m.clients {
+	rooms := make([]int, 0, len(m.rooms))
+	for roomID := range m.rooms {
 		rooms = append(rooms, roomID)
 	}
 
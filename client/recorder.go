@@ -0,0 +1,219 @@
+package client
+
+import (
+	"TianHe-API/utils"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// 录制文件格式：
+//
+//	文件头  magic "THRC" | 版本 1字节 | 房间号 uint32 | 开始时间 int64纳秒，均为大端
+//	每一帧  距上一帧的纳秒数 uvarint | 长度 uvarint | 原始数据
+//
+// 第一帧的时间差相对于文件头中的开始时间
+const (
+	recordingMagic   = "THRC"
+	recordingVersion = 1
+
+	recordingHeaderLength = 4 + 1 + 4 + 8
+)
+
+// 单帧的最大长度，超过时认为录制文件已损坏
+const maxRecordedFrameLength = 16 << 20
+
+// RecordedFrame 录制的一帧原始数据
+type RecordedFrame struct {
+	Time time.Time
+	Data []byte
+}
+
+// Recorder 把收到的原始数据帧连同接收时间写入录制文件
+type Recorder struct {
+	mutex  sync.Mutex
+	writer *bufio.Writer
+	closer io.Closer
+	last   time.Time
+	buf    [2 * binary.MaxVarintLen64]byte
+}
+
+// CreateRecorder 创建录制文件
+func CreateRecorder(path string, roomID int) (*Recorder, error) {
+	file, err := utils.CreateFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder, err := NewRecorder(file, roomID)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	recorder.closer = file
+	return recorder, nil
+}
+
+// NewRecorder 在w上录制，w实现io.Closer时不会被Close关闭
+func NewRecorder(w io.Writer, roomID int) (*Recorder, error) {
+	r := &Recorder{
+		writer: bufio.NewWriter(w),
+		last:   time.Now(),
+	}
+
+	header := make([]byte, recordingHeaderLength)
+	copy(header, recordingMagic)
+	header[4] = recordingVersion
+	binary.BigEndian.PutUint32(header[5:9], uint32(roomID))
+	binary.BigEndian.PutUint64(header[9:17], uint64(r.last.UnixNano()))
+
+	if _, err := r.writer.Write(header); err != nil {
+		return nil, err
+	}
+	if err := r.writer.Flush(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record 写入一帧，t早于上一帧时按上一帧的时间记录
+// 每帧都立即写入文件，程序崩溃时录制也是完整的，这正是最需要录制的时候
+func (r *Recorder) Record(t time.Time, data []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.writer == nil {
+		return errors.New("录制已关闭")
+	}
+
+	delta := t.Sub(r.last)
+	if delta < 0 {
+		delta = 0
+	}
+	r.last = r.last.Add(delta)
+
+	n := binary.PutUvarint(r.buf[:], uint64(delta))
+	n += binary.PutUvarint(r.buf[n:], uint64(len(data)))
+	if _, err := r.writer.Write(r.buf[:n]); err != nil {
+		return err
+	}
+	if _, err := r.writer.Write(data); err != nil {
+		return err
+	}
+	return r.writer.Flush()
+}
+
+// Close 写出剩余数据并关闭文件
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.writer == nil {
+		return nil
+	}
+
+	err := r.writer.Flush()
+	r.writer = nil
+	if r.closer != nil {
+		if closeErr := r.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// RecordingReader 按顺序读取录制文件中的帧
+type RecordingReader struct {
+	reader *bufio.Reader
+	closer io.Closer
+	roomID int
+	last   time.Time
+}
+
+// OpenRecording 打开录制文件
+func OpenRecording(path string) (*RecordingReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := NewRecordingReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return reader, nil
+}
+
+// NewRecordingReader 从r读取录制数据，r实现io.Closer时由Close关闭
+func NewRecordingReader(r io.Reader) (*RecordingReader, error) {
+	reader := &RecordingReader{reader: bufio.NewReader(r)}
+	if closer, ok := r.(io.Closer); ok {
+		reader.closer = closer
+	}
+
+	header := make([]byte, recordingHeaderLength)
+	if _, err := io.ReadFull(reader.reader, header); err != nil {
+		return nil, fmt.Errorf("读取录制文件头失败: %v", err)
+	}
+	if string(header[0:4]) != recordingMagic {
+		return nil, errors.New("不是录制文件")
+	}
+	if header[4] != recordingVersion {
+		return nil, fmt.Errorf("不支持的录制文件版本: %d", header[4])
+	}
+
+	reader.roomID = int(binary.BigEndian.Uint32(header[5:9]))
+	reader.last = time.Unix(0, int64(binary.BigEndian.Uint64(header[9:17])))
+	return reader, nil
+}
+
+// RoomID 录制时的房间号
+func (r *RecordingReader) RoomID() int {
+	return r.roomID
+}
+
+// Next 读取下一帧，读完时返回io.EOF
+func (r *RecordingReader) Next() (*RecordedFrame, error) {
+	delta, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if length > maxRecordedFrameLength {
+		return nil, fmt.Errorf("录制帧长度异常: %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	r.last = r.last.Add(time.Duration(delta))
+	return &RecordedFrame{Time: r.last, Data: data}, nil
+}
+
+// Close 关闭录制文件
+func (r *RecordingReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// 帧中间截断时不能当作正常读完
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
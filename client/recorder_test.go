@@ -0,0 +1,187 @@
+package client
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/config"
+	"TianHe-API/event"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+const giftBody = `{"cmd":"SEND_GIFT","data":{"action":"投喂","batch_combo_id":"batch:gift:combo_id:12345678:4370836:31036:1700000000.1234","coin_type":"gold","giftId":31036,"giftName":"小花花","num":1,"price":100,"timestamp":1700000000,"total_coin":100,"uid":12345678,"uname":"测试观众"}}`
+
+// record 把帧按给定间隔录制到内存中
+func record(t *testing.T, interval time.Duration, frames ...[]byte) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(&buf, 21452505)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i, frame := range frames {
+		if err := recorder.Record(start.Add(time.Duration(i)*interval), frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestRecordingRoundTrip(t *testing.T) {
+	frames := [][]byte{messageFrame(danmuBody), messageFrame(giftBody), {}}
+	buf := record(t, 150*time.Millisecond, frames...)
+
+	reader, err := NewRecordingReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reader.RoomID() != 21452505 {
+		t.Errorf("RoomID = %d", reader.RoomID())
+	}
+
+	var last time.Time
+	for i, want := range frames {
+		frame, err := reader.Next()
+		if err != nil {
+			t.Fatalf("第%d帧: %v", i, err)
+		}
+		if !bytes.Equal(frame.Data, want) {
+			t.Errorf("第%d帧数据不一致", i)
+		}
+		if i > 0 && frame.Time.Sub(last) != 150*time.Millisecond {
+			t.Errorf("第%d帧间隔 %v", i, frame.Time.Sub(last))
+		}
+		last = frame.Time
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("读完后 err = %v, want io.EOF", err)
+	}
+}
+
+func TestRecorderWritesEachFrame(t *testing.T) {
+	var buf bytes.Buffer
+	recorder, err := NewRecorder(&buf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Record(time.Now(), messageFrame(danmuBody)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未Close时已写入的帧也能完整读出
+	reader, err := NewRecordingReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); err != nil {
+		t.Fatalf("未Close时读取失败: %v", err)
+	}
+}
+
+func TestRecordingTruncated(t *testing.T) {
+	data := record(t, 0, messageFrame(danmuBody)).Bytes()
+
+	reader, err := NewRecordingReader(bytes.NewReader(data[:len(data)-5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Next(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("err = %v, want io.ErrUnexpectedEOF", err)
+	}
+
+	if _, err := NewRecordingReader(bytes.NewReader([]byte("not a recording file"))); err == nil {
+		t.Error("不是录制文件时应返回错误")
+	}
+}
+
+// replay 回放录制并收集事件总线上的所有事件
+func replay(t *testing.T, buf *bytes.Buffer, speed float64) ([]*event.Event, *DanmuClient) {
+	t.Helper()
+
+	reader, err := NewRecordingReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := event.NewBus()
+	var events []*event.Event
+	bus.SubscribeFunc(event.Filter{}, func(e *event.Event) {
+		events = append(events, e)
+	})
+
+	c := NewDanmuClient(reader.RoomID(), 0, config.NewConfig(), api.NewClient(), bus)
+	if err := c.Replay(context.Background(), NewReplayTransport(reader, speed)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("回放没有结束")
+	}
+	c.FlushGifts()
+	return events, c
+}
+
+func TestReplayThroughClient(t *testing.T) {
+	buf := record(t, time.Hour, messageFrame(danmuBody), messageFrame(giftBody))
+
+	start := time.Now()
+	events, c := replay(t, buf, ReplayMaxSpeed)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("最快速度回放耗时 %v", elapsed)
+	}
+
+	var types []event.Type
+	for _, e := range events {
+		if e.RoomID != 21452505 {
+			t.Errorf("事件房间号 = %d", e.RoomID)
+		}
+		types = append(types, e.Type)
+	}
+	want := []event.Type{event.TypeDanmu, event.TypeGift, event.TypeGiftSettled}
+	if len(types) != len(want) {
+		t.Fatalf("事件 = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Errorf("事件 = %v, want %v", types, want)
+			break
+		}
+	}
+
+	danmu, _ := events[0].Danmu()
+	if danmu.Text != "你好" {
+		t.Errorf("弹幕内容 = %q", danmu.Text)
+	}
+	settled, _ := events[2].GiftSettled()
+	if settled.GiftName != "小花花" || settled.CNY != 0.1 {
+		t.Errorf("礼物结算 = %+v", settled)
+	}
+	if reason := c.DisconnectReason(); reason != ErrReplayFinished.Error() {
+		t.Errorf("DisconnectReason = %q", reason)
+	}
+}
+
+func TestReplaySpeed(t *testing.T) {
+	buf := record(t, 200*time.Millisecond, messageFrame(danmuBody), messageFrame(danmuBody))
+
+	// 4倍速时两帧间隔50ms
+	start := time.Now()
+	events, _ := replay(t, buf, 4)
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("4倍速回放只用了 %v", elapsed)
+	}
+	if len(events) != 2 {
+		t.Errorf("收到 %d 个事件", len(events))
+	}
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Transport 弹幕服务器的底层连接
+// 只负责收发原始数据，认证、心跳和解压均由DanmuClient在其上完成
+type Transport interface {
+	// Dial 连接到指定服务器，header仅对WebSocket连接有效，ctx只控制建立连接的过程
+	Dial(ctx context.Context, endpoint Endpoint, header http.Header) error
+	// SendPacket 发送数据包，可被多个协程并发调用
+	SendPacket(packet *protocol.Packet) error
+	// ReadFrame 阻塞读取一帧原始数据，一帧中可能包含多个数据包
+	ReadFrame() ([]byte, error)
+	// Close 关闭连接，阻塞中的ReadFrame会返回错误
+	Close() error
+}
+
+// TransportFactory 按连接方式创建Transport
+type TransportFactory func(scheme string) (Transport, error)
+
+// NewTransport 默认的Transport工厂
+func NewTransport(scheme string) (Transport, error) {
+	switch scheme {
+	case SchemeWSS, SchemeWS:
+		return NewWSTransport(), nil
+	case SchemeTCP:
+		return NewTCPTransport(), nil
+	default:
+		return nil, fmt.Errorf("不支持的连接方式: %s", scheme)
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SchemeReplay 回放录制文件时使用的连接方式
+const SchemeReplay = "replay"
+
+// ReplayMaxSpeed 不等待帧间隔，尽快回放
+const ReplayMaxSpeed = 0
+
+// ErrReplayFinished 录制文件已回放完
+var ErrReplayFinished = errors.New("回放结束")
+
+// ReplayTransport 把录制文件当作服务器连接，按录制时的帧间隔返回数据
+//
+// speed为1时按原速回放，大于1时加速，ReplayMaxSpeed表示不等待。发送的数据包会被丢弃
+type ReplayTransport struct {
+	reader *RecordingReader
+	speed  float64
+	last   time.Time // 上一帧的录制时间
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReplayTransport 创建回放连接，Close时会关闭reader
+func NewReplayTransport(reader *RecordingReader, speed float64) *ReplayTransport {
+	return &ReplayTransport{
+		reader: reader,
+		speed:  speed,
+		closed: make(chan struct{}),
+	}
+}
+
+// Dial 回放不需要建立连接
+func (t *ReplayTransport) Dial(ctx context.Context, endpoint Endpoint, header http.Header) error {
+	return ctx.Err()
+}
+
+// SendPacket 丢弃发送的数据包
+func (t *ReplayTransport) SendPacket(packet *protocol.Packet) error {
+	select {
+	case <-t.closed:
+		return errors.New("连接已关闭")
+	default:
+		return nil
+	}
+}
+
+// ReadFrame 按录制时的间隔返回下一帧，读完后返回ErrReplayFinished
+func (t *ReplayTransport) ReadFrame() ([]byte, error) {
+	select {
+	case <-t.closed:
+		return nil, errors.New("连接已关闭")
+	default:
+	}
+
+	frame, err := t.reader.Next()
+	if err == io.EOF {
+		return nil, ErrReplayFinished
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if t.speed > 0 && !t.last.IsZero() {
+		if wait := time.Duration(float64(frame.Time.Sub(t.last)) / t.speed); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-t.closed:
+				timer.Stop()
+				return nil, errors.New("连接已关闭")
+			}
+		}
+	}
+	t.last = frame.Time
+
+	return frame.Data, nil
+}
+
+// Close 停止回放
+func (t *ReplayTransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = t.reader.Close()
+	})
+	return err
+}
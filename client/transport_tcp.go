@@ -0,0 +1,90 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 单个数据包的最大长度，超过时认为数据流已错位
+const maxTCPPacketLength = 4 << 20
+
+// TCPTransport 基于原始TCP的连接，每次读取一个完整的顶层数据包
+type TCPTransport struct {
+	conn       net.Conn
+	writeMutex sync.Mutex
+}
+
+// NewTCPTransport 创建TCP连接
+func NewTCPTransport() *TCPTransport {
+	return &TCPTransport{}
+}
+
+// Dial 建立TCP连接
+func (t *TCPTransport) Dial(ctx context.Context, endpoint Endpoint, header http.Header) error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint.Address())
+	if err != nil {
+		return err
+	}
+
+	t.conn = conn
+	return nil
+}
+
+// SendPacket 发送数据包
+func (t *TCPTransport) SendPacket(packet *protocol.Packet) error {
+	if t.conn == nil {
+		return errors.New("连接未建立")
+	}
+
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := t.conn.Write(packet.Encode())
+	return err
+}
+
+// ReadFrame 按包头中的长度读取一个完整的数据包
+func (t *TCPTransport) ReadFrame() ([]byte, error) {
+	if t.conn == nil {
+		return nil, errors.New("连接未建立")
+	}
+
+	// 读取包头
+	headerBuf := make([]byte, protocol.HeaderLength)
+	if _, err := io.ReadFull(t.conn, headerBuf); err != nil {
+		return nil, err
+	}
+
+	// 流式连接中包长度异常后无法再定位下一个包，只能断开
+	packetLength := binary.BigEndian.Uint32(headerBuf[0:4])
+	if packetLength < protocol.HeaderLength || packetLength > maxTCPPacketLength {
+		return nil, fmt.Errorf("数据包长度异常: %d", packetLength)
+	}
+
+	// 读取完整数据包
+	data := make([]byte, packetLength)
+	copy(data, headerBuf)
+	if _, err := io.ReadFull(t.conn, data[protocol.HeaderLength:]); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Close 关闭连接
+func (t *TCPTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"TianHe-API/protocol"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WSTransport 基于WebSocket的连接，按Endpoint的Scheme区分ws和wss
+type WSTransport struct {
+	conn       *websocket.Conn
+	writeMutex sync.Mutex
+}
+
+// NewWSTransport 创建WebSocket连接
+func NewWSTransport() *WSTransport {
+	return &WSTransport{}
+}
+
+// Dial 建立WebSocket连接
+func (t *WSTransport) Dial(ctx context.Context, endpoint Endpoint, header http.Header) error {
+	u := url.URL{
+		Scheme: endpoint.Scheme,
+		Host:   endpoint.Address(),
+		Path:   "/sub",
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	conn, _, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		return err
+	}
+
+	t.conn = conn
+	return nil
+}
+
+// SendPacket 以二进制消息发送数据包
+func (t *WSTransport) SendPacket(packet *protocol.Packet) error {
+	if t.conn == nil {
+		return errors.New("连接未建立")
+	}
+
+	// gorilla/websocket不支持并发写
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	t.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return t.conn.WriteMessage(websocket.BinaryMessage, packet.Encode())
+}
+
+// ReadFrame 读取一条二进制消息，忽略其他类型的消息
+func (t *WSTransport) ReadFrame() ([]byte, error) {
+	if t.conn == nil {
+		return nil, errors.New("连接未建立")
+	}
+
+	for {
+		messageType, data, err := t.conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		if messageType == websocket.BinaryMessage {
+			return data, nil
+		}
+	}
+}
+
+// Close 关闭连接
+func (t *WSTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 断开原因
+const (
+	ReasonClosed           = "主动关闭"
+	ReasonHeartbeatTimeout = "心跳回应超时"
+	ReasonMessageTimeout   = "消息接收超时"
+)
+
+// watchdog 记录心跳回应和消息的最后到达时间，用于发现半开连接
+type watchdog struct {
+	heartbeatTimeout time.Duration // 为0时不检查心跳回应
+	messageTimeout   time.Duration // 为0时不检查消息
+
+	mutex              sync.Mutex
+	lastHeartbeatReply time.Time
+	lastMessage        time.Time
+}
+
+func newWatchdog(heartbeatTimeout, messageTimeout time.Duration) *watchdog {
+	return &watchdog{
+		heartbeatTimeout: heartbeatTimeout,
+		messageTimeout:   messageTimeout,
+	}
+}
+
+// reset 连接建立时重置计时，给新连接留出宽限期
+func (w *watchdog) reset(now time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.lastHeartbeatReply = now
+	w.lastMessage = now
+}
+
+// heartbeatReplied 记录收到心跳回应
+func (w *watchdog) heartbeatReplied(now time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.lastHeartbeatReply = now
+}
+
+// messageReceived 记录收到消息
+func (w *watchdog) messageReceived(now time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.lastMessage = now
+}
+
+// check 检查是否超时，返回超时原因，未超时时返回空字符串
+func (w *watchdog) check(now time.Time) string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.heartbeatTimeout > 0 {
+		if elapsed := now.Sub(w.lastHeartbeatReply); elapsed > w.heartbeatTimeout {
+			return fmt.Sprintf("%s(%v未收到)", ReasonHeartbeatTimeout, elapsed.Round(time.Second))
+		}
+	}
+
+	if w.messageTimeout > 0 {
+		if elapsed := now.Sub(w.lastMessage); elapsed > w.messageTimeout {
+			return fmt.Sprintf("%s(%v未收到)", ReasonMessageTimeout, elapsed.Round(time.Second))
+		}
+	}
+
+	return ""
+}
+
+// interval 检查间隔，取超时时间的五分之一，至少1秒
+func (w *watchdog) interval() time.Duration {
+	timeout := w.heartbeatTimeout
+	if timeout == 0 || (w.messageTimeout > 0 && w.messageTimeout < timeout) {
+		timeout = w.messageTimeout
+	}
+
+	interval := timeout / 5
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+// times 获取最后一次心跳回应和消息的时间
+func (w *watchdog) times() (time.Time, time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return w.lastHeartbeatReply, w.lastMessage
+}
@@ -8,24 +8,93 @@ import (
 )
 
 type Config struct {
-	RoomIDs     []int  `json:"room_ids"`
-	DanmuServer string `json:"danmu_server"`
-	DanmuPort   int    `json:"danmu_port"`
-	LogLevel    string `json:"log_level"`
-	CookiePath  string `json:"cookie_path"`
-	MaxRetries  int    `json:"max_retries"`
-	RetryDelay  int    `json:"retry_delay"`
+	RoomIDs         []int  `json:"room_ids"`
+	DanmuServer     string `json:"danmu_server"`
+	DanmuPort       int    `json:"danmu_port"`
+	LogLevel        string `json:"log_level"`
+	CookiePath      string `json:"cookie_path"`
+	MaxRetries      int    `json:"max_retries"`      // 连续失败多少次后放弃，<=0表示无限重试
+	RetryDelay      int    `json:"retry_delay"`      // 首次重试等待秒数
+	ProtocolVersion int    `json:"protocol_version"` // 认证时协商的协议版本：2为zlib压缩，3为brotli压缩
+	Console         bool   `json:"console"`          // 是否在控制台打印事件
+
+	Transports     []string         `json:"transports"`      // 连接方式的尝试顺序，可选wss、ws、tcp
+	RoomTransports map[int][]string `json:"room_transports"` // 单独指定某些房间的连接方式
+
+	// 重连退避
+	RetryMaxDelay    int     `json:"retry_max_delay"`   // 重试等待秒数上限
+	RetryMultiplier  float64 `json:"retry_multiplier"`  // 每次失败后等待时间的倍数
+	RetryJitter      float64 `json:"retry_jitter"`      // 等待时间的随机浮动比例
+	RetryResetAfter  int     `json:"retry_reset_after"` // 连接稳定多少秒后清零失败次数
+	BreakerThreshold int     `json:"breaker_threshold"` // 连续失败多少次后熔断，<=0表示不熔断
+	BreakerCooldown  int     `json:"breaker_cooldown"`  // 熔断冷却秒数
+
+	// 心跳与看门狗
+	HeartbeatInterval int `json:"heartbeat_interval"` // 心跳发送间隔秒数
+	HeartbeatTimeout  int `json:"heartbeat_timeout"`  // 多少秒未收到心跳回应视为连接已死，0表示不检查
+	MessageTimeout    int `json:"message_timeout"`    // 多少秒未收到任何消息视为连接已死，0表示不检查
+
+	DispatchQueueSize int `json:"dispatch_queue_size"` // 每个房间待处理消息队列的长度，满时按优先级丢弃
+	GiftComboWindow   int `json:"gift_combo_window"`   // 连击礼物停止多少秒后结算，<=0表示每个礼物单独结算
+
+	GiftConfigPath string `json:"gift_config_path"` // 礼物配置的本地缓存文件，接口不可用时从这里加载，为空表示不使用
+
+	RoomInfoInterval int `json:"room_info_interval"` // 拉取房间信息校正开播状态的间隔秒数，0表示只依赖弹幕消息
+
+	// 事件归档
+	ArchiveDir          string `json:"archive_dir"`           // 事件归档目录，为空表示不归档
+	ArchiveMaxSize      int    `json:"archive_max_size"`      // 单个归档文件的最大MB数，0表示不按大小切分
+	ArchiveRotateAfter  int    `json:"archive_rotate_after"`  // 单个归档文件最多写入多少分钟，0表示只在跨天时切分
+	ArchiveSyncInterval int    `json:"archive_sync_interval"` // 归档刷盘间隔秒数
+	ArchiveCompress     bool   `json:"archive_compress"`      // 是否gzip压缩切分后的归档文件
+
+	RecordDir string `json:"record_dir"` // 录制服务器原始数据帧的目录，用于排查解析问题，为空表示不录制
+
+	// 历史记录数据库
+	StorePath          string `json:"store_path"`           // SQLite数据库文件，为空表示不存储
+	StoreBatchSize     int    `json:"store_batch_size"`     // 攒够多少条事件写入一次
+	StoreFlushInterval int    `json:"store_flush_interval"` // 事件不足一批时最多等待多少秒写入
 }
 
 func NewConfig() *Config {
 	cfg := &Config{
-		RoomIDs:     []int{21452505}, // 默认房间号
-		DanmuServer: "broadcastlv.chat.bilibili.com",
-		DanmuPort:   2243,
-		LogLevel:    "info",
-		CookiePath:  "config/cookie.json",
-		MaxRetries:  3,
-		RetryDelay:  5,
+		RoomIDs:         []int{21452505}, // 默认房间号
+		DanmuServer:     "broadcastlv.chat.bilibili.com",
+		DanmuPort:       2243,
+		LogLevel:        "info",
+		CookiePath:      "config/cookie.json",
+		MaxRetries:      0, // 默认无限重试
+		RetryDelay:      5,
+		ProtocolVersion: 3,
+		Console:         true,
+		Transports:      []string{"wss", "ws", "tcp"},
+
+		RetryMaxDelay:    300,
+		RetryMultiplier:  2,
+		RetryJitter:      0.2,
+		RetryResetAfter:  60,
+		BreakerThreshold: 10,
+		BreakerCooldown:  600,
+
+		HeartbeatInterval: 30,
+		HeartbeatTimeout:  70,
+
+		DispatchQueueSize: 1024,
+		GiftComboWindow:   5,
+
+		GiftConfigPath: "config/gift_config.json",
+
+		RoomInfoInterval: 60,
+
+		ArchiveDir:          "", // 默认不归档
+		ArchiveMaxSize:      64,
+		ArchiveRotateAfter:  60,
+		ArchiveSyncInterval: 5,
+		ArchiveCompress:     true,
+
+		StorePath:          "", // 默认不存储
+		StoreBatchSize:     200,
+		StoreFlushInterval: 1,
 	}
 
 	// 从环境变量读取房间号
@@ -45,6 +114,14 @@ func NewConfig() *Config {
 	return cfg
 }
 
+// 获取房间使用的连接方式，未单独配置时使用全局配置
+func (c *Config) GetTransports(roomID int) []string {
+	if transports, ok := c.RoomTransports[roomID]; ok && len(transports) > 0 {
+		return transports
+	}
+	return c.Transports
+}
+
 // Cookie 结构
 type Cookie struct {
 	SESSDATA          string `json:"SESSDATA"`
@@ -52,6 +129,7 @@ type Cookie struct {
 	DedeUserID        string `json:"DedeUserID"`
 	DedeUserID__ckMd5 string `json:"DedeUserID__ckMd5"`
 	Sid               string `json:"sid"`
+	Buvid3            string `json:"buvid3,omitempty"`
 	ExpireTime        int64  `json:"expire_time"`
 }
 
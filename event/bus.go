@@ -0,0 +1,214 @@
+package event
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Filter 订阅过滤条件，字段为空表示不限制
+type Filter struct {
+	Types   []Type
+	RoomIDs []int // 真实房间号或短号均可
+}
+
+// Match 检查事件是否符合过滤条件
+func (f Filter) Match(e *Event) bool {
+	if len(f.Types) > 0 {
+		matched := false
+		for _, t := range f.Types {
+			if t == e.Type {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(f.RoomIDs) > 0 {
+		matched := false
+		for _, roomID := range f.RoomIDs {
+			if roomID == e.RoomID || (e.ShortID != 0 && roomID == e.ShortID) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Subscription 一个订阅
+type Subscription struct {
+	// C 通道订阅的事件通道，回调订阅时为nil，取消订阅后会被关闭
+	C <-chan *Event
+
+	id      uint64
+	bus     *Bus
+	filter  Filter
+	ch      chan *Event
+	fn      func(*Event)
+	dropped uint64
+}
+
+// Dropped 因通道已满而丢弃的事件数
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe 取消订阅
+func (s *Subscription) Unsubscribe() {
+	s.bus.unsubscribe(s.id)
+}
+
+// Bus 进程内事件总线
+type Bus struct {
+	mutex  sync.RWMutex
+	subs   map[uint64]*Subscription
+	nextID uint64
+}
+
+// NewBus 创建事件总线
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[uint64]*Subscription),
+	}
+}
+
+// Subscribe 以带缓冲的通道订阅事件，通道满时新事件会被丢弃并计数
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	ch := make(chan *Event, buffer)
+	return b.add(&Subscription{
+		C:      ch,
+		filter: filter,
+		ch:     ch,
+	})
+}
+
+// SubscribeFunc 以回调订阅事件，回调在发布者协程中同步执行，不应阻塞，也不能在回调中取消订阅
+func (b *Bus) SubscribeFunc(filter Filter, fn func(*Event)) *Subscription {
+	return b.add(&Subscription{
+		filter: filter,
+		fn:     fn,
+	})
+}
+
+func (b *Bus) add(sub *Subscription) *Subscription {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.nextID++
+	sub.id = b.nextID
+	sub.bus = b
+	b.subs[sub.id] = sub
+
+	return sub
+}
+
+func (b *Bus) unsubscribe(id uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sub, ok := b.subs[id]
+	if !ok {
+		return
+	}
+
+	delete(b.subs, id)
+	if sub.ch != nil {
+		close(sub.ch)
+	}
+}
+
+// Publish 发布事件
+func (b *Bus) Publish(e *Event) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+
+		if sub.fn != nil {
+			sub.fn(e)
+			continue
+		}
+
+		select {
+		case sub.ch <- e:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+		}
+	}
+}
+
+// Close 取消所有订阅
+func (b *Bus) Close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for id, sub := range b.subs {
+		delete(b.subs, id)
+		if sub.ch != nil {
+			close(sub.ch)
+		}
+	}
+}
+
+// Emitter 绑定了房间的事件发布器，发布的事件会带上当前的直播场次
+type Emitter struct {
+	bus     *Bus
+	roomID  int
+	shortID int
+
+	mutex     sync.RWMutex
+	sessionID string
+}
+
+// Emitter 创建绑定房间的事件发布器
+func (b *Bus) Emitter(roomID, shortID int) *Emitter {
+	return &Emitter{
+		bus:     b,
+		roomID:  roomID,
+		shortID: shortID,
+	}
+}
+
+// RoomID 获取发布器绑定的真实房间号
+func (e *Emitter) RoomID() int {
+	return e.roomID
+}
+
+// SetSession 设置当前直播场次，之后发布的事件都会带上它，下播时设为空
+func (e *Emitter) SetSession(sessionID string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.sessionID = sessionID
+}
+
+// Session 获取当前直播场次
+func (e *Emitter) Session() string {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	return e.sessionID
+}
+
+// Emit 发布一个本房间的事件
+func (e *Emitter) Emit(t Type, data interface{}) {
+	e.bus.Publish(&Event{
+		Type:      t,
+		RoomID:    e.roomID,
+		ShortID:   e.shortID,
+		SessionID: e.Session(),
+		Time:      time.Now(),
+		Data:      data,
+	})
+}
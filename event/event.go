@@ -0,0 +1,195 @@
+package event
+
+import (
+	"TianHe-API/model"
+	"time"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeDanmu     Type = "danmu"      // 弹幕，Data为*model.DanmuMessage
+	TypeGift      Type = "gift"       // 礼物，Data为*model.GiftMessage，每条SEND_GIFT一个
+	TypeWelcome   Type = "welcome"    // 进房，Data为*model.WelcomeMessage
+	TypeFollow    Type = "follow"     // 关注，Data为*model.FollowMessage
+	TypeGuard     Type = "guard"      // 上舰，Data为*model.GuardMessage
+	TypeSuperChat Type = "super_chat" // 醒目留言，Data为*model.SuperChatMessage
+	TypeOnline    Type = "online"     // 在线人数，Data为*model.LiveStats
+
+	TypeGiftSettled      Type = "gift_settled"       // 合并后的礼物连击，Data为*model.GiftSettledMessage
+	TypeShare            Type = "share"              // 分享直播间，Data为*model.ShareMessage
+	TypeLike             Type = "like"               // 点赞，Data为*model.LikeMessage
+	TypeLikeCount        Type = "like_count"         // 点赞总数，Data为*model.LikeCount
+	TypeWatched          Type = "watched"            // 看过人数，Data为*model.WatchedCount
+	TypeEntryEffect      Type = "entry_effect"       // 进场特效，Data为*model.EntryEffectMessage
+	TypeLive             Type = "live"               // 开播，Data为*model.LiveStatusMessage
+	TypePreparing        Type = "preparing"          // 下播，Data为*model.LiveStatusMessage
+	TypeRoomChange       Type = "room_change"        // 标题或分区变更，Data为*model.RoomChangeMessage
+	TypeSessionStart     Type = "session_start"      // 一场直播开始，Data为*model.LiveSession
+	TypeSessionEnd       Type = "session_end"        // 一场直播结束，Data为*model.LiveSession
+	TypeBlock            Type = "block"              // 禁言，Data为*model.BlockMessage
+	TypeWarning          Type = "warning"            // 超管警告，Data为*model.WarningMessage
+	TypeCutOff           Type = "cut_off"            // 直播被切断，Data为*model.WarningMessage
+	TypeSuperChatDelete  Type = "super_chat_delete"  // 醒目留言删除，Data为*model.SuperChatDeleteMessage
+	TypeSuperChatExpire  Type = "super_chat_expire"  // 醒目留言展示结束，Data为*model.SuperChatMessage
+	TypeOnlineRank       Type = "online_rank"        // 高能榜，Data为*model.OnlineRank
+	TypeRecallDanmu      Type = "recall_danmu"       // 弹幕撤回，Data为*model.RecallDanmuMessage
+	TypeRedPocket        Type = "red_pocket"         // 人气红包，Data为*model.RedPocketMessage
+	TypeRedPocketWinners Type = "red_pocket_winners" // 人气红包中奖名单，Data为*model.RedPocketWinnerMessage
+	TypeUnknown          Type = "unknown"            // 未识别的命令，Data为*model.RawMessage
+)
+
+// Event 直播间事件
+type Event struct {
+	Type      Type        `json:"type"`
+	RoomID    int         `json:"room_id"`              // 真实房间号
+	ShortID   int         `json:"short_id,omitempty"`   // 房间短号
+	SessionID string      `json:"session_id,omitempty"` // 所属直播场次，未开播时为空
+	Time      time.Time   `json:"time"`                 // 事件产生时间
+	Data      interface{} `json:"data"`
+}
+
+// Danmu 获取弹幕数据
+func (e *Event) Danmu() (*model.DanmuMessage, bool) {
+	data, ok := e.Data.(*model.DanmuMessage)
+	return data, ok
+}
+
+// Gift 获取礼物数据
+func (e *Event) Gift() (*model.GiftMessage, bool) {
+	data, ok := e.Data.(*model.GiftMessage)
+	return data, ok
+}
+
+// GiftSettled 获取合并后的礼物数据
+func (e *Event) GiftSettled() (*model.GiftSettledMessage, bool) {
+	data, ok := e.Data.(*model.GiftSettledMessage)
+	return data, ok
+}
+
+// Welcome 获取进房数据
+func (e *Event) Welcome() (*model.WelcomeMessage, bool) {
+	data, ok := e.Data.(*model.WelcomeMessage)
+	return data, ok
+}
+
+// Follow 获取关注数据
+func (e *Event) Follow() (*model.FollowMessage, bool) {
+	data, ok := e.Data.(*model.FollowMessage)
+	return data, ok
+}
+
+// Guard 获取上舰数据
+func (e *Event) Guard() (*model.GuardMessage, bool) {
+	data, ok := e.Data.(*model.GuardMessage)
+	return data, ok
+}
+
+// SuperChat 获取醒目留言数据
+func (e *Event) SuperChat() (*model.SuperChatMessage, bool) {
+	data, ok := e.Data.(*model.SuperChatMessage)
+	return data, ok
+}
+
+// Online 获取在线人数数据
+func (e *Event) Online() (*model.LiveStats, bool) {
+	data, ok := e.Data.(*model.LiveStats)
+	return data, ok
+}
+
+// Share 获取分享数据
+func (e *Event) Share() (*model.ShareMessage, bool) {
+	data, ok := e.Data.(*model.ShareMessage)
+	return data, ok
+}
+
+// Like 获取点赞数据
+func (e *Event) Like() (*model.LikeMessage, bool) {
+	data, ok := e.Data.(*model.LikeMessage)
+	return data, ok
+}
+
+// LikeCount 获取点赞总数数据
+func (e *Event) LikeCount() (*model.LikeCount, bool) {
+	data, ok := e.Data.(*model.LikeCount)
+	return data, ok
+}
+
+// Watched 获取看过人数数据
+func (e *Event) Watched() (*model.WatchedCount, bool) {
+	data, ok := e.Data.(*model.WatchedCount)
+	return data, ok
+}
+
+// EntryEffect 获取进场特效数据
+func (e *Event) EntryEffect() (*model.EntryEffectMessage, bool) {
+	data, ok := e.Data.(*model.EntryEffectMessage)
+	return data, ok
+}
+
+// LiveStatus 获取开播、下播数据
+func (e *Event) LiveStatus() (*model.LiveStatusMessage, bool) {
+	data, ok := e.Data.(*model.LiveStatusMessage)
+	return data, ok
+}
+
+// RoomChange 获取房间信息变更数据
+func (e *Event) RoomChange() (*model.RoomChangeMessage, bool) {
+	data, ok := e.Data.(*model.RoomChangeMessage)
+	return data, ok
+}
+
+// Session 获取直播场次数据
+func (e *Event) Session() (*model.LiveSession, bool) {
+	data, ok := e.Data.(*model.LiveSession)
+	return data, ok
+}
+
+// Block 获取禁言数据
+func (e *Event) Block() (*model.BlockMessage, bool) {
+	data, ok := e.Data.(*model.BlockMessage)
+	return data, ok
+}
+
+// Warning 获取超管警告或切断直播数据
+func (e *Event) Warning() (*model.WarningMessage, bool) {
+	data, ok := e.Data.(*model.WarningMessage)
+	return data, ok
+}
+
+// SuperChatDelete 获取醒目留言删除数据
+func (e *Event) SuperChatDelete() (*model.SuperChatDeleteMessage, bool) {
+	data, ok := e.Data.(*model.SuperChatDeleteMessage)
+	return data, ok
+}
+
+// OnlineRank 获取高能榜数据
+func (e *Event) OnlineRank() (*model.OnlineRank, bool) {
+	data, ok := e.Data.(*model.OnlineRank)
+	return data, ok
+}
+
+// RecallDanmu 获取弹幕撤回数据
+func (e *Event) RecallDanmu() (*model.RecallDanmuMessage, bool) {
+	data, ok := e.Data.(*model.RecallDanmuMessage)
+	return data, ok
+}
+
+// RedPocket 获取人气红包数据
+func (e *Event) RedPocket() (*model.RedPocketMessage, bool) {
+	data, ok := e.Data.(*model.RedPocketMessage)
+	return data, ok
+}
+
+// RedPocketWinners 获取人气红包中奖名单数据
+func (e *Event) RedPocketWinners() (*model.RedPocketWinnerMessage, bool) {
+	data, ok := e.Data.(*model.RedPocketWinnerMessage)
+	return data, ok
+}
+
+// Raw 获取未识别消息的原始数据
+func (e *Event) Raw() (*model.RawMessage, bool) {
+	data, ok := e.Data.(*model.RawMessage)
+	return data, ok
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/utils"
+	"fmt"
+	"time"
+)
+
+// PrintEvent 将事件输出到控制台并写入日志，可作为事件总线的回调订阅者
+func PrintEvent(e *event.Event) {
+	switch e.Type {
+	case event.TypeDanmu:
+		danmu, _ := e.Danmu()
+		fmt.Printf("[房间%d-弹幕] %s%s: %s\n", e.RoomID, medalLabel(danmu.Medal), danmu.UserName, danmu.Text)
+		utils.Logger.Infof("房间%d 弹幕 - %s: %s", e.RoomID, danmu.UserName, danmu.Text)
+	case event.TypeGift:
+		// 单条礼物只记调试日志，连击结算后再输出
+		gift, _ := e.Gift()
+		utils.Logger.Debugf("房间%d 礼物 - %s: %d个%s", e.RoomID, gift.UserName, gift.Num, gift.GiftName)
+	case event.TypeGiftSettled:
+		gift, _ := e.GiftSettled()
+		fmt.Printf("[房间%d-礼物] %s 送出了 %d 个 %s (%s)\n",
+			e.RoomID, gift.UserName, gift.Num, gift.GiftName, valueLabel(gift.CoinType, gift.CNY))
+		utils.Logger.Infof("房间%d 礼物 - %s: %d个%s", e.RoomID, gift.UserName, gift.Num, gift.GiftName)
+	case event.TypeWelcome:
+		welcome, _ := e.Welcome()
+		fmt.Printf("[房间%d-进房] %s 进入了直播间\n", e.RoomID, welcome.UserName)
+		utils.Logger.Infof("房间%d 进房 - %s", e.RoomID, welcome.UserName)
+	case event.TypeFollow:
+		follow, _ := e.Follow()
+		if follow.UserName != "" {
+			fmt.Printf("[房间%d-关注] %s 关注了主播\n", e.RoomID, follow.UserName)
+		} else {
+			fmt.Printf("[房间%d-关注] 有用户关注了主播\n", e.RoomID)
+		}
+		utils.Logger.Infof("房间%d 关注事件", e.RoomID)
+	case event.TypeGuard:
+		guard, _ := e.Guard()
+		action := "开通"
+		if guard.Renewal {
+			action = "续费"
+		}
+		fmt.Printf("[房间%d-上舰] %s %s了 %d %s%s (%s)\n",
+			e.RoomID, guard.UserName, action, guard.Num, guard.Unit, guard.GiftName, valueLabel(guard.CoinType, guard.CNY))
+		utils.Logger.Infof("房间%d 上舰 - %s: %s", e.RoomID, guard.UserName, guard.GiftName)
+	case event.TypeSuperChat:
+		superChat, _ := e.SuperChat()
+		fmt.Printf("[房间%d-SC] %s (￥%.0f): %s\n", e.RoomID, superChat.UserName, superChat.CNY, superChat.Message)
+		utils.Logger.Infof("房间%d SC - %s: %s", e.RoomID, superChat.UserName, superChat.Message)
+	case event.TypeSuperChatDelete:
+		deleted, _ := e.SuperChatDelete()
+		utils.Logger.Infof("房间%d SC被删除: %v", e.RoomID, deleted.IDs)
+	case event.TypeSuperChatExpire:
+		superChat, _ := e.SuperChat()
+		utils.Logger.Debugf("房间%d SC展示结束 - %s: %s", e.RoomID, superChat.UserName, superChat.Message)
+	case event.TypeOnline:
+		stats, _ := e.Online()
+		utils.Logger.Debugf("房间%d 在线人数: %d", e.RoomID, stats.OnlineCount)
+	case event.TypeShare:
+		share, _ := e.Share()
+		fmt.Printf("[房间%d-分享] %s 分享了直播间\n", e.RoomID, share.UserName)
+		utils.Logger.Infof("房间%d 分享 - %s", e.RoomID, share.UserName)
+	case event.TypeLike:
+		like, _ := e.Like()
+		utils.Logger.Debugf("房间%d 点赞 - %s", e.RoomID, like.UserName)
+	case event.TypeLive:
+		fmt.Printf("[房间%d-开播] 直播开始\n", e.RoomID)
+		utils.Logger.Infof("房间%d 开播", e.RoomID)
+	case event.TypePreparing:
+		fmt.Printf("[房间%d-下播] 直播结束\n", e.RoomID)
+		utils.Logger.Infof("房间%d 下播", e.RoomID)
+	case event.TypeRoomChange:
+		change, _ := e.RoomChange()
+		fmt.Printf("[房间%d-信息] 标题: %s 分区: %s\n", e.RoomID, change.Title, change.AreaName)
+		utils.Logger.Infof("房间%d 信息变更 - %s %s", e.RoomID, change.Title, change.AreaName)
+	case event.TypeSessionStart:
+		session, _ := e.Session()
+		fmt.Printf("[房间%d-场次] %s 开始: %s\n", e.RoomID, session.ID, session.Title)
+		utils.Logger.Infof("房间%d 场次开始 - %s %s", e.RoomID, session.ID, session.Title)
+	case event.TypeSessionEnd:
+		session, _ := e.Session()
+		duration := session.EndTime.Sub(session.StartTime).Round(time.Second)
+		fmt.Printf("[房间%d-场次] %s 结束，时长 %v\n", e.RoomID, session.ID, duration)
+		utils.Logger.Infof("房间%d 场次结束 - %s %v", e.RoomID, session.ID, duration)
+	case event.TypeBlock:
+		block, _ := e.Block()
+		fmt.Printf("[房间%d-禁言] %s 被禁言\n", e.RoomID, block.UserName)
+		utils.Logger.Infof("房间%d 禁言 - %s", e.RoomID, block.UserName)
+	case event.TypeWarning, event.TypeCutOff:
+		warning, _ := e.Warning()
+		fmt.Printf("[房间%d-警告] %s\n", e.RoomID, warning.Message)
+		utils.Logger.Warnf("房间%d 超管警告(切断: %v) - %s", e.RoomID, warning.CutOff, warning.Message)
+	case event.TypeRedPocket:
+		pocket, _ := e.RedPocket()
+		fmt.Printf("[房间%d-红包] %s 送出了人气红包 (%s)\n",
+			e.RoomID, pocket.SenderName, valueLabel(model.CoinGold, float64(pocket.Price)/10))
+		utils.Logger.Infof("房间%d 红包 - %s: %d", e.RoomID, pocket.SenderName, pocket.Price)
+	case event.TypeUnknown:
+		raw, _ := e.Raw()
+		utils.Logger.Debugf("房间%d 未识别的消息: %s", e.RoomID, raw.Cmd)
+	}
+}
+
+// medalLabel 粉丝勋章的显示文本，未佩戴时为空
+func medalLabel(medal *model.FanMedal) string {
+	if medal == nil {
+		return ""
+	}
+	return fmt.Sprintf("[%s %d] ", medal.Name, medal.Level)
+}
+
+// valueLabel 礼物价值的显示文本
+func valueLabel(coinType string, cny float64) string {
+	if coinType == model.CoinSilver {
+		return "免费"
+	}
+	return fmt.Sprintf("价值: ￥%.1f", cny)
+}
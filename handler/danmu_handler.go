@@ -1,45 +1,48 @@
 package handler
 
 import (
-	"TianHe-API/model"
-	"TianHe-API/utils"
-	"fmt"
-	"time"
+	"TianHe-API/event"
+	"TianHe-API/parser"
+	"TianHe-API/protocol"
 )
 
+// MessageHandler 消息处理器，解析失败时返回错误，不会panic
 type MessageHandler interface {
-	Handle(data map[string]interface{})
+	Handle(msg *protocol.Message) error
 }
 
 type DanmuHandler struct {
-	roomID int
+	emitter *event.Emitter
 }
 
-func NewDanmuHandler(roomID int) *DanmuHandler {
-	return &DanmuHandler{roomID: roomID}
+func NewDanmuHandler(emitter *event.Emitter) *DanmuHandler {
+	return &DanmuHandler{emitter: emitter}
 }
 
-func (h *DanmuHandler) Handle(data map[string]interface{}) {
-	info, ok := data["info"].([]interface{})
-	if !ok || len(info) < 3 {
-		return
+func (h *DanmuHandler) Handle(msg *protocol.Message) error {
+	danmu, err := parser.ParseDanmu(msg)
+	if err != nil {
+		return err
 	}
 
-	// 解析弹幕信息
-	danmuInfo := info[0].([]interface{})
-	text := info[1].(string)
-	userInfo := info[2].([]interface{})
-
-	danmu := &model.DanmuMessage{
-		Text:      text,
-		UserName:  userInfo[1].(string),
-		UserID:    int64(userInfo[0].(float64)),
-		Timestamp: time.Now(),
-		Color:     fmt.Sprintf("#%06x", int(danmuInfo[3].(float64))),
-		FontSize:  int(danmuInfo[2].(float64)),
+	h.emitter.Emit(event.TypeDanmu, danmu)
+	return nil
+}
+
+type RecallDanmuHandler struct {
+	emitter *event.Emitter
+}
+
+func NewRecallDanmuHandler(emitter *event.Emitter) *RecallDanmuHandler {
+	return &RecallDanmuHandler{emitter: emitter}
+}
+
+func (h *RecallDanmuHandler) Handle(msg *protocol.Message) error {
+	recall, err := parser.ParseRecallDanmu(msg)
+	if err != nil {
+		return err
 	}
 
-	// 输出弹幕
-	fmt.Printf("[房间%d-弹幕] %s: %s\n", h.roomID, danmu.UserName, danmu.Text)
-	utils.Logger.Infof("房间%d 弹幕 - %s: %s", h.roomID, danmu.UserName, danmu.Text)
+	h.emitter.Emit(event.TypeRecallDanmu, recall)
+	return nil
 }
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"sync"
+	"time"
+)
+
+// GiftAggregator 按连击批次ID合并礼物，批次在窗口期内没有新礼物时发布一次结算事件
+//
+// COMBO_SEND中的累计数量和价值比逐条累加更可靠，收到时以它为准校正合计
+type GiftAggregator struct {
+	emitter *event.Emitter
+	window  time.Duration
+	now     func() time.Time
+	mutex   sync.Mutex
+	pending map[string]*giftCombo
+
+	// 最近结算的批次及结算时间，保留一个窗口期，用于丢弃结算后才到达的COMBO_SEND
+	settled map[string]time.Time
+}
+
+type giftCombo struct {
+	settled *model.GiftSettledMessage
+	timer   *time.Timer
+
+	// 逐条累加的合计和COMBO_SEND给出的最大累计值，结算时取较大者
+	giftNum    int
+	giftValue  int64
+	comboNum   int
+	comboValue int64
+}
+
+// finish 计算最终合计
+func (c *giftCombo) finish() *model.GiftSettledMessage {
+	c.settled.Num = c.giftNum
+	c.settled.Value = c.giftValue
+	if c.comboNum >= c.giftNum && c.comboNum > 0 {
+		c.settled.Num = c.comboNum
+		if c.comboValue > 0 {
+			c.settled.Value = c.comboValue
+		}
+		c.settled.Reconciled = true
+	}
+	c.settled.CNY = model.CoinToCNY(c.settled.CoinType, c.settled.Value)
+	return c.settled
+}
+
+// NewGiftAggregator 创建礼物合并器，window<=0时每个礼物单独结算
+func NewGiftAggregator(emitter *event.Emitter, window time.Duration) *GiftAggregator {
+	return &GiftAggregator{
+		emitter: emitter,
+		window:  window,
+		now:     time.Now,
+		pending: make(map[string]*giftCombo),
+		settled: make(map[string]time.Time),
+	}
+}
+
+// AddGift 加入一条SEND_GIFT
+func (a *GiftAggregator) AddGift(gift *model.GiftMessage) {
+	if a.window <= 0 || gift.BatchComboID == "" {
+		a.emitter.Emit(event.TypeGiftSettled, &model.GiftSettledMessage{
+			BatchComboID: gift.BatchComboID,
+			GiftName:     gift.GiftName,
+			GiftID:       gift.GiftID,
+			UserName:     gift.UserName,
+			UserID:       gift.UserID,
+			Num:          gift.Num,
+			Value:        gift.TotalPrice,
+			CoinType:     gift.CoinType,
+			CNY:          gift.CNY,
+			Sends:        1,
+			StartTime:    gift.Timestamp,
+			EndTime:      gift.Timestamp,
+		})
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	combo := a.combo(gift.BatchComboID, gift.Timestamp)
+	combo.giftNum += gift.Num
+	combo.giftValue += gift.TotalPrice
+
+	settled := combo.settled
+	settled.GiftName = gift.GiftName
+	settled.GiftID = gift.GiftID
+	settled.UserName = gift.UserName
+	settled.UserID = gift.UserID
+	settled.CoinType = gift.CoinType
+	settled.Sends++
+	settled.EndTime = gift.Timestamp
+}
+
+// AddCombo 用COMBO_SEND的累计值校正合计
+func (a *GiftAggregator) AddCombo(combo *model.ComboMessage) {
+	if a.window <= 0 {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	// 批次结算后迟到的COMBO_SEND只有累计值没有新礼物，不能再开一个批次
+	if a.recentlySettled(combo.BatchComboID) {
+		return
+	}
+
+	pending := a.combo(combo.BatchComboID, combo.Timestamp)
+
+	// COMBO_SEND可能乱序到达，只保留最大的累计值
+	if combo.TotalNum > pending.comboNum {
+		pending.comboNum = combo.TotalNum
+		pending.comboValue = combo.TotalCoin
+	}
+
+	settled := pending.settled
+	if settled.GiftName == "" {
+		settled.GiftName = combo.GiftName
+		settled.GiftID = combo.GiftID
+		settled.UserName = combo.UserName
+		settled.UserID = combo.UserID
+	}
+	settled.EndTime = combo.Timestamp
+}
+
+// combo 获取或创建批次并重新开始计时，调用时需持有锁
+func (a *GiftAggregator) combo(id string, start time.Time) *giftCombo {
+	if combo, ok := a.pending[id]; ok {
+		combo.timer.Reset(a.window)
+		return combo
+	}
+
+	combo := &giftCombo{
+		settled: &model.GiftSettledMessage{
+			BatchComboID: id,
+			StartTime:    start,
+		},
+	}
+	combo.timer = time.AfterFunc(a.window, func() {
+		a.settle(id, combo)
+	})
+	a.pending[id] = combo
+	return combo
+}
+
+// settle 结算批次，批次已被结算或替换时忽略
+func (a *GiftAggregator) settle(id string, combo *giftCombo) {
+	a.mutex.Lock()
+	if a.pending[id] != combo {
+		a.mutex.Unlock()
+		return
+	}
+	delete(a.pending, id)
+	a.markSettled(id)
+	a.mutex.Unlock()
+
+	a.emitter.Emit(event.TypeGiftSettled, combo.finish())
+}
+
+// markSettled 记录已结算的批次并清理超过窗口期的记录，调用时需持有锁
+func (a *GiftAggregator) markSettled(id string) {
+	now := a.now()
+	for settledID, at := range a.settled {
+		if now.Sub(at) >= a.window {
+			delete(a.settled, settledID)
+		}
+	}
+	a.settled[id] = now
+}
+
+// recentlySettled 批次是否在一个窗口期内结算过，调用时需持有锁
+func (a *GiftAggregator) recentlySettled(id string) bool {
+	at, ok := a.settled[id]
+	return ok && a.now().Sub(at) < a.window
+}
+
+// Flush 立即结算所有未结束的批次
+func (a *GiftAggregator) Flush() {
+	a.mutex.Lock()
+	pending := a.pending
+	a.pending = make(map[string]*giftCombo)
+	for id := range pending {
+		a.markSettled(id)
+	}
+	a.mutex.Unlock()
+
+	for _, combo := range pending {
+		combo.timer.Stop()
+		a.emitter.Emit(event.TypeGiftSettled, combo.finish())
+	}
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"sync"
+	"testing"
+	"time"
+)
+
+const batchID = "batch:gift:combo_id:12345678:4370836:31036:1700000000.1234"
+
+// newTestAggregator 创建合并器并收集它发布的结算事件，时间由返回的指针控制
+func newTestAggregator(window time.Duration) (*GiftAggregator, *time.Time, func() []*model.GiftSettledMessage) {
+	bus := event.NewBus()
+	var mutex sync.Mutex
+	var settled []*model.GiftSettledMessage
+	bus.SubscribeFunc(event.Filter{}, func(e *event.Event) {
+		if msg, ok := e.GiftSettled(); ok {
+			mutex.Lock()
+			settled = append(settled, msg)
+			mutex.Unlock()
+		}
+	})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	aggregator := NewGiftAggregator(bus.Emitter(21452505, 0), window)
+	aggregator.now = func() time.Time { return now }
+	return aggregator, &now, func() []*model.GiftSettledMessage {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]*model.GiftSettledMessage(nil), settled...)
+	}
+}
+
+func gift(num int) *model.GiftMessage {
+	return &model.GiftMessage{
+		BatchComboID: batchID,
+		GiftName:     "小花花",
+		GiftID:       31036,
+		UserName:     "测试观众",
+		UserID:       12345678,
+		Num:          num,
+		TotalPrice:   int64(num) * 100,
+		CoinType:     "gold",
+	}
+}
+
+func combo(total int) *model.ComboMessage {
+	return &model.ComboMessage{
+		BatchComboID: batchID,
+		GiftName:     "小花花",
+		GiftID:       31036,
+		UserName:     "测试观众",
+		UserID:       12345678,
+		TotalNum:     total,
+		TotalCoin:    int64(total) * 100,
+	}
+}
+
+func TestGiftAggregatorReconcile(t *testing.T) {
+	aggregator, _, settled := newTestAggregator(time.Hour)
+
+	aggregator.AddGift(gift(1))
+	aggregator.AddCombo(combo(3))
+	aggregator.AddGift(gift(1))
+	aggregator.Flush()
+
+	got := settled()
+	if len(got) != 1 {
+		t.Fatalf("结算 %d 次", len(got))
+	}
+	if got[0].Num != 3 || got[0].Value != 300 || !got[0].Reconciled || got[0].Sends != 2 {
+		t.Errorf("结算 = %+v", got[0])
+	}
+}
+
+func TestGiftAggregatorLateCombo(t *testing.T) {
+	aggregator, now, settled := newTestAggregator(time.Hour)
+
+	aggregator.AddGift(gift(1))
+	aggregator.Flush()
+
+	// 窗口期内迟到的COMBO_SEND被丢弃
+	aggregator.AddCombo(combo(5))
+	aggregator.Flush()
+	if got := settled(); len(got) != 1 {
+		t.Fatalf("迟到的COMBO_SEND产生了第二次结算: %+v", got[len(got)-1])
+	}
+
+	// 超过窗口期后同一批次ID重新计入
+	*now = now.Add(time.Hour)
+	aggregator.AddCombo(combo(5))
+	aggregator.Flush()
+	if got := settled(); len(got) != 2 {
+		t.Fatalf("结算 %d 次", len(got))
+	}
+}
+
+func TestGiftAggregatorLateComboAfterTimer(t *testing.T) {
+	aggregator, _, settled := newTestAggregator(20 * time.Millisecond)
+	aggregator.now = time.Now
+
+	aggregator.AddGift(gift(1))
+	deadline := time.Now().Add(time.Second)
+	for len(settled()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("批次没有按时结算")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	aggregator.AddCombo(combo(2))
+	aggregator.Flush()
+	if got := settled(); len(got) != 1 {
+		t.Fatalf("结算 %d 次", len(got))
+	}
+}
@@ -1,81 +1,320 @@
 package handler
 
 import (
+	"TianHe-API/api"
+	"TianHe-API/event"
 	"TianHe-API/model"
-	"TianHe-API/utils"
+	"TianHe-API/parser"
+	"TianHe-API/protocol"
 	"fmt"
+	"sync"
 	"time"
 )
 
+// GiftHandler 用礼物配置补全礼物信息后发布，并交给合并器按连击结算
 type GiftHandler struct {
-	roomID int
+	emitter    *event.Emitter
+	aggregator *GiftAggregator
+	gifts      *api.GiftCache
 }
 
-func NewGiftHandler(roomID int) *GiftHandler {
-	return &GiftHandler{roomID: roomID}
+func NewGiftHandler(emitter *event.Emitter, aggregator *GiftAggregator, gifts *api.GiftCache) *GiftHandler {
+	return &GiftHandler{emitter: emitter, aggregator: aggregator, gifts: gifts}
 }
 
-func (h *GiftHandler) Handle(data map[string]interface{}) {
-	giftData, ok := data["data"].(map[string]interface{})
+func (h *GiftHandler) Handle(msg *protocol.Message) error {
+	gift, err := parser.ParseGift(msg)
+	if err != nil {
+		return err
+	}
+
+	h.applyGiftInfo(gift)
+	h.emitter.Emit(event.TypeGift, gift)
+	h.aggregator.AddGift(gift)
+	return nil
+}
+
+// applyGiftInfo 补全消息中缺失的货币类型和单价，并填写礼物图标
+func (h *GiftHandler) applyGiftInfo(gift *model.GiftMessage) {
+	if h.gifts == nil {
+		return
+	}
+
+	info, ok := h.gifts.Get(gift.GiftID)
 	if !ok {
 		return
 	}
 
-	gift := &model.GiftMessage{
-		GiftName:  giftData["giftName"].(string),
-		GiftID:    int(giftData["giftId"].(float64)),
-		UserName:  giftData["uname"].(string),
-		UserID:    int64(giftData["uid"].(float64)),
-		Num:       int(giftData["num"].(float64)),
-		Price:     int(giftData["price"].(float64)),
-		Timestamp: time.Now(),
+	gift.GiftIcon = info.Icon
+	if gift.CoinType == "" {
+		gift.CoinType = info.CoinType
+	}
+	if gift.Price == 0 {
+		gift.Price = info.Price
+		gift.TotalPrice = int64(gift.Num) * int64(info.Price)
+	}
+	gift.CNY = model.CoinToCNY(gift.CoinType, gift.TotalPrice)
+}
+
+type ComboSendHandler struct {
+	aggregator *GiftAggregator
+}
+
+func NewComboSendHandler(aggregator *GiftAggregator) *ComboSendHandler {
+	return &ComboSendHandler{aggregator: aggregator}
+}
+
+func (h *ComboSendHandler) Handle(msg *protocol.Message) error {
+	combo, err := parser.ParseComboSend(msg)
+	if err != nil {
+		return err
 	}
 
-	// 输出礼物信息
-	fmt.Printf("[房间%d-礼物] %s 送出了 %d 个 %s (价值: %d)\n",
-		h.roomID, gift.UserName, gift.Num, gift.GiftName, gift.Price)
-	utils.Logger.Infof("房间%d 礼物 - %s: %d个%s", h.roomID, gift.UserName, gift.Num, gift.GiftName)
+	h.aggregator.AddCombo(combo)
+	return nil
 }
 
 type WelcomeHandler struct {
-	roomID int
+	emitter *event.Emitter
 }
 
-func NewWelcomeHandler(roomID int) *WelcomeHandler {
-	return &WelcomeHandler{roomID: roomID}
+func NewWelcomeHandler(emitter *event.Emitter) *WelcomeHandler {
+	return &WelcomeHandler{emitter: emitter}
 }
 
-func (h *WelcomeHandler) Handle(data map[string]interface{}) {
-	welcomeData, ok := data["data"].(map[string]interface{})
-	if !ok {
-		return
+func (h *WelcomeHandler) Handle(msg *protocol.Message) error {
+	welcome, err := parser.ParseWelcome(msg)
+	if err != nil {
+		return err
 	}
 
-	welcome := &model.WelcomeMessage{
-		UserName:  welcomeData["uname"].(string),
-		UserID:    int64(welcomeData["uid"].(float64)),
-		Timestamp: time.Now(),
-		IsVip:     welcomeData["vip"].(float64) > 0,
+	h.emitter.Emit(event.TypeWelcome, welcome)
+	return nil
+}
+
+// 同一次开通的GUARD_BUY和USER_TOAST_MSG相隔不会超过这个时间
+const guardMergeWindow = 3 * time.Second
+
+// GuardHandler 处理GUARD_BUY和USER_TOAST_MSG
+//
+// 两者在同一次开通时成对下发，只有USER_TOAST_MSG带有续费信息。先到的GUARD_BUY会等待
+// 一小段时间与USER_TOAST_MSG合并，超时未等到则单独发布，同一次开通只发布一次事件
+type GuardHandler struct {
+	emitter *event.Emitter
+	roster  *GuardRoster
+	mutex   sync.Mutex
+	pending map[string]*pendingGuard
+	toasted map[string]time.Time // 已单独发布的USER_TOAST_MSG，用于丢弃迟到的GUARD_BUY
+}
+
+type pendingGuard struct {
+	guard *model.GuardMessage
+	timer *time.Timer
+}
+
+func NewGuardHandler(emitter *event.Emitter, roster *GuardRoster) *GuardHandler {
+	return &GuardHandler{
+		emitter: emitter,
+		roster:  roster,
+		pending: make(map[string]*pendingGuard),
+		toasted: make(map[string]time.Time),
+	}
+}
+
+func (h *GuardHandler) Handle(msg *protocol.Message) error {
+	if msg.Cmd == protocol.CmdUserToast {
+		guard, err := parser.ParseUserToast(msg)
+		if err != nil {
+			return err
+		}
+
+		h.handleToast(guard)
+		return nil
 	}
 
-	fmt.Printf("[房间%d-进房] %s 进入了直播间\n", h.roomID, welcome.UserName)
-	utils.Logger.Infof("房间%d 进房 - %s", h.roomID, welcome.UserName)
+	guard, err := parser.ParseGuard(msg)
+	if err != nil {
+		return err
+	}
+
+	h.handleBuy(guard)
+	return nil
 }
 
-type FollowHandler struct {
-	roomID int
+func guardKey(guard *model.GuardMessage) string {
+	return fmt.Sprintf("%d:%d:%d", guard.UserID, guard.GuardLevel, guard.Num)
 }
 
-func NewFollowHandler(roomID int) *FollowHandler {
-	return &FollowHandler{roomID: roomID}
+func (h *GuardHandler) handleBuy(guard *model.GuardMessage) {
+	key := guardKey(guard)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.cleanToasted()
+	if _, ok := h.toasted[key]; ok {
+		delete(h.toasted, key)
+		return
+	}
+
+	pending := &pendingGuard{guard: guard}
+	pending.timer = time.AfterFunc(guardMergeWindow, func() {
+		h.flush(key, pending)
+	})
+	h.pending[key] = pending
+}
+
+func (h *GuardHandler) handleToast(toast *model.GuardMessage) {
+	key := guardKey(toast)
+
+	h.mutex.Lock()
+	pending, ok := h.pending[key]
+	if ok {
+		pending.timer.Stop()
+		delete(h.pending, key)
+
+		// 礼物名和时间以GUARD_BUY为准
+		toast.GiftName = pending.guard.GiftName
+		toast.Timestamp = pending.guard.Timestamp
+		if toast.Price == 0 {
+			toast.Price = pending.guard.Price
+			toast.TotalPrice = pending.guard.TotalPrice
+			toast.CNY = pending.guard.CNY
+		}
+	} else {
+		h.cleanToasted()
+		h.toasted[key] = time.Now()
+	}
+	h.mutex.Unlock()
+
+	h.publish(toast)
 }
 
-func (h *FollowHandler) Handle(data map[string]interface{}) {
-	msgType, ok := data["msg_type"].(float64)
-	if !ok || msgType != 2 {
+// flush 等待超时，单独发布GUARD_BUY
+func (h *GuardHandler) flush(key string, pending *pendingGuard) {
+	h.mutex.Lock()
+	if h.pending[key] != pending {
+		h.mutex.Unlock()
 		return
 	}
+	delete(h.pending, key)
+	h.mutex.Unlock()
+
+	h.publish(pending.guard)
+}
+
+// Flush 立即发布所有等待合并的GUARD_BUY，停止时调用，避免定时器在事件订阅者关闭后才触发
+func (h *GuardHandler) Flush() {
+	h.mutex.Lock()
+	pending := h.pending
+	h.pending = make(map[string]*pendingGuard)
+	h.mutex.Unlock()
+
+	for _, p := range pending {
+		p.timer.Stop()
+		h.publish(p.guard)
+	}
+}
+
+// cleanToasted 清理过期的记录，调用时需持有锁
+func (h *GuardHandler) cleanToasted() {
+	for key, at := range h.toasted {
+		if time.Since(at) > guardMergeWindow {
+			delete(h.toasted, key)
+		}
+	}
+}
+
+func (h *GuardHandler) publish(guard *model.GuardMessage) {
+	h.roster.Apply(guard)
+	h.emitter.Emit(event.TypeGuard, guard)
+}
+
+type WelcomeGuardHandler struct {
+	emitter *event.Emitter
+}
+
+func NewWelcomeGuardHandler(emitter *event.Emitter) *WelcomeGuardHandler {
+	return &WelcomeGuardHandler{emitter: emitter}
+}
+
+func (h *WelcomeGuardHandler) Handle(msg *protocol.Message) error {
+	welcome, err := parser.ParseWelcomeGuard(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeWelcome, welcome)
+	return nil
+}
+
+// SuperChatHandler 处理SUPER_CHAT_MESSAGE和SUPER_CHAT_MESSAGE_JPN，重复下发的留言只发布一次
+type SuperChatHandler struct {
+	emitter *event.Emitter
+	tracker *SuperChatTracker
+}
+
+func NewSuperChatHandler(emitter *event.Emitter, tracker *SuperChatTracker) *SuperChatHandler {
+	return &SuperChatHandler{emitter: emitter, tracker: tracker}
+}
+
+func (h *SuperChatHandler) Handle(msg *protocol.Message) error {
+	superChat, err := parser.ParseSuperChat(msg)
+	if err != nil {
+		return err
+	}
+
+	if h.tracker.Add(superChat) {
+		h.emitter.Emit(event.TypeSuperChat, superChat)
+	}
+	return nil
+}
+
+type SuperChatDeleteHandler struct {
+	emitter *event.Emitter
+	tracker *SuperChatTracker
+}
+
+func NewSuperChatDeleteHandler(emitter *event.Emitter, tracker *SuperChatTracker) *SuperChatDeleteHandler {
+	return &SuperChatDeleteHandler{emitter: emitter, tracker: tracker}
+}
+
+func (h *SuperChatDeleteHandler) Handle(msg *protocol.Message) error {
+	deleted, err := parser.ParseSuperChatDelete(msg)
+	if err != nil {
+		return err
+	}
+
+	h.tracker.Delete(deleted.IDs)
+	h.emitter.Emit(event.TypeSuperChatDelete, deleted)
+	return nil
+}
+
+// RedPocketHandler 处理POPULARITY_RED_POCKET_*
+type RedPocketHandler struct {
+	emitter *event.Emitter
+}
+
+func NewRedPocketHandler(emitter *event.Emitter) *RedPocketHandler {
+	return &RedPocketHandler{emitter: emitter}
+}
+
+func (h *RedPocketHandler) Handle(msg *protocol.Message) error {
+	if msg.Cmd == protocol.CmdRedPocketWinners {
+		winners, err := parser.ParseRedPocketWinners(msg)
+		if err != nil {
+			return err
+		}
+
+		h.emitter.Emit(event.TypeRedPocketWinners, winners)
+		return nil
+	}
+
+	pocket, err := parser.ParseRedPocket(msg)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("[房间%d-关注] 有用户关注了主播\n", h.roomID)
-	utils.Logger.Infof("房间%d 关注事件", h.roomID)
+	h.emitter.Emit(event.TypeRedPocket, pocket)
+	return nil
 }
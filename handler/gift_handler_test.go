@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	guardBuyBody  = `{"cmd":"GUARD_BUY","data":{"uid":12345678,"username":"测试观众","guard_level":3,"num":1,"price":198000,"gift_id":10003,"gift_name":"舰长","start_time":1700000000,"end_time":1700000000}}`
+	userToastBody = `{"cmd":"USER_TOAST_MSG","data":{"uid":12345678,"username":"测试观众","guard_level":3,"num":1,"price":138000,"role_name":"舰长","unit":"月","op_type":2,"start_time":1700000000,"toast_msg":"<%测试观众%> 续费了舰长"}}`
+)
+
+func parseMessage(t *testing.T, body string) *protocol.Message {
+	t.Helper()
+
+	msg, err := protocol.ParseMessage([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// newTestGuardHandler 创建上舰处理器并收集它发布的事件
+func newTestGuardHandler() (*GuardHandler, *GuardRoster, func() []*model.GuardMessage) {
+	bus := event.NewBus()
+	var mutex sync.Mutex
+	var guards []*model.GuardMessage
+	bus.SubscribeFunc(event.Filter{Types: []event.Type{event.TypeGuard}}, func(e *event.Event) {
+		guard, _ := e.Guard()
+		mutex.Lock()
+		guards = append(guards, guard)
+		mutex.Unlock()
+	})
+
+	roster := NewGuardRoster()
+	return NewGuardHandler(bus.Emitter(21452505, 0), roster), roster, func() []*model.GuardMessage {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]*model.GuardMessage(nil), guards...)
+	}
+}
+
+func TestGuardHandlerMerge(t *testing.T) {
+	tests := []struct {
+		name  string
+		order []string
+	}{
+		{"GUARD_BUY先到", []string{guardBuyBody, userToastBody}},
+		{"USER_TOAST_MSG先到", []string{userToastBody, guardBuyBody}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, roster, guards := newTestGuardHandler()
+			for _, body := range tt.order {
+				if err := h.Handle(parseMessage(t, body)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h.Flush()
+
+			got := guards()
+			if len(got) != 1 {
+				t.Fatalf("同一次开通发布了 %d 次", len(got))
+			}
+			guard := got[0]
+			if !guard.Renewal || guard.Unit != "月" || guard.ToastMsg != "测试观众 续费了舰长" {
+				t.Errorf("没有合并USER_TOAST_MSG的续费信息: %+v", guard)
+			}
+			if guard.UserID != 12345678 || guard.GuardLevel != 3 || guard.GiftName != "舰长" {
+				t.Errorf("上舰 = %+v", guard)
+			}
+			if _, ok := roster.Get(12345678); !ok {
+				t.Error("名单中没有新开通的成员")
+			}
+		})
+	}
+}
+
+func TestGuardHandlerFlush(t *testing.T) {
+	h, _, guards := newTestGuardHandler()
+	if err := h.Handle(parseMessage(t, guardBuyBody)); err != nil {
+		t.Fatal(err)
+	}
+	if len(guards()) != 0 {
+		t.Fatal("GUARD_BUY没有等待合并")
+	}
+
+	// 停止时立即发布，之后定时器不会再发布一次
+	start := time.Now()
+	h.Flush()
+	if got := guards(); len(got) != 1 || got[0].Price != 198000 {
+		t.Fatalf("Flush后的上舰 = %+v", got)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Flush等待了合并窗口")
+	}
+
+	h.Flush()
+	time.Sleep(10 * time.Millisecond)
+	if len(guards()) != 1 {
+		t.Error("重复发布了上舰")
+	}
+}
+
+func TestGiftHandlerAppliesGiftInfo(t *testing.T) {
+	// 离线时从本地保存的礼物配置补全
+	gifts := api.NewGiftCache()
+	if err := gifts.LoadFile("../api/testdata/gift_config.json"); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := event.NewBus()
+	var received []*model.GiftMessage
+	bus.SubscribeFunc(event.Filter{Types: []event.Type{event.TypeGift}}, func(e *event.Event) {
+		gift, _ := e.Gift()
+		received = append(received, gift)
+	})
+	emitter := bus.Emitter(21452505, 0)
+	h := NewGiftHandler(emitter, NewGiftAggregator(emitter, 0), gifts)
+
+	bodies := []string{
+		// 缺少单价和货币类型
+		`{"cmd":"SEND_GIFT","data":{"giftId":10003,"giftName":"舰长","num":2,"uid":12345678,"uname":"测试观众"}}`,
+		// 银瓜子礼物补全后仍不折算人民币
+		`{"cmd":"SEND_GIFT","data":{"giftId":1,"giftName":"辣条","num":10,"uid":12345678,"uname":"测试观众"}}`,
+		// 配置中没有的礼物保持原样
+		`{"cmd":"SEND_GIFT","data":{"giftId":99999,"giftName":"新礼物","num":1,"price":500,"coin_type":"gold","uid":12345678,"uname":"测试观众"}}`,
+	}
+	for _, body := range bodies {
+		if err := h.Handle(parseMessage(t, body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(received) != 3 {
+		t.Fatalf("收到 %d 个礼物", len(received))
+	}
+	captain := received[0]
+	if captain.Price != 198000 || captain.TotalPrice != 396000 || captain.CoinType != model.CoinGold || captain.CNY != 396 || captain.GiftIcon == "" {
+		t.Errorf("舰长 = %+v", captain)
+	}
+	silver := received[1]
+	if silver.Price != 100 || silver.CoinType != model.CoinSilver || silver.CNY != 0 {
+		t.Errorf("辣条 = %+v", silver)
+	}
+	unknown := received[2]
+	if unknown.TotalPrice != 500 || unknown.CNY != 0.5 || unknown.GiftIcon != "" {
+		t.Errorf("新礼物 = %+v", unknown)
+	}
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// GuardRoster 直播间的大航海名单，可以从接口初始化，之后由开通事件维持
+type GuardRoster struct {
+	mutex   sync.RWMutex
+	members map[int64]*model.GuardMember
+}
+
+// NewGuardRoster 创建空的大航海名单
+func NewGuardRoster() *GuardRoster {
+	return &GuardRoster{
+		members: make(map[int64]*model.GuardMember),
+	}
+}
+
+// Seed 用接口返回的名单替换当前名单，已知的到期时间会被保留
+//
+// 名单与弹幕连接同时加载，接口可能还没包含刚开通的成员，由开通事件得到的未到期成员不会被移除
+func (r *GuardRoster) Seed(guards []api.GuardInfo) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	members := make(map[int64]*model.GuardMember, len(guards))
+	for _, guard := range guards {
+		member := &model.GuardMember{
+			UserName:   guard.UserName,
+			UserID:     guard.UserID,
+			GuardLevel: guard.GuardLevel,
+		}
+		if old, ok := r.members[guard.UserID]; ok {
+			member.ExpireAt = old.ExpireAt
+		}
+		members[guard.UserID] = member
+	}
+
+	now := time.Now()
+	for uid, old := range r.members {
+		if _, ok := members[uid]; !ok && old.ExpireAt.After(now) {
+			members[uid] = old
+		}
+	}
+
+	r.members = members
+}
+
+// Apply 按开通或续费记录更新名单，到期时间从当前到期时间或开通时间起累加
+func (r *GuardRoster) Apply(guard *model.GuardMessage) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	member, ok := r.members[guard.UserID]
+	if !ok {
+		member = &model.GuardMember{UserID: guard.UserID}
+		r.members[guard.UserID] = member
+	}
+
+	member.UserName = guard.UserName
+	if member.GuardLevel == 0 || (guard.GuardLevel > 0 && guard.GuardLevel < member.GuardLevel) {
+		member.GuardLevel = guard.GuardLevel
+	}
+
+	start := guard.Timestamp
+	if member.ExpireAt.After(start) {
+		start = member.ExpireAt
+	}
+	member.ExpireAt = addGuardDuration(start, guard.Num, guard.Unit)
+}
+
+// addGuardDuration 按数量和单位计算到期时间，单位未知时按月计算
+func addGuardDuration(start time.Time, num int, unit string) time.Time {
+	switch unit {
+	case "天":
+		return start.AddDate(0, 0, num)
+	case "周":
+		return start.AddDate(0, 0, 7*num)
+	default:
+		return start.AddDate(0, num, 0)
+	}
+}
+
+// Get 查询成员
+func (r *GuardRoster) Get(uid int64) (model.GuardMember, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	member, ok := r.members[uid]
+	if !ok {
+		return model.GuardMember{}, false
+	}
+	return *member, true
+}
+
+// List 获取全部成员，按等级从高到低排序
+func (r *GuardRoster) List() []model.GuardMember {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list := make([]model.GuardMember, 0, len(r.members))
+	for _, member := range r.members {
+		list = append(list, *member)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].GuardLevel != list[j].GuardLevel {
+			return list[i].GuardLevel < list[j].GuardLevel
+		}
+		return list[i].UserID < list[j].UserID
+	})
+
+	return list
+}
+
+// Expiring 获取到期时间已知且在within之内到期的成员，按到期时间排序
+func (r *GuardRoster) Expiring(within time.Duration) []model.GuardMember {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	deadline := time.Now().Add(within)
+	var list []model.GuardMember
+	for _, member := range r.members {
+		if !member.ExpireAt.IsZero() && member.ExpireAt.Before(deadline) {
+			list = append(list, *member)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ExpireAt.Before(list[j].ExpireAt)
+	})
+
+	return list
+}
+
+// Prune 移除已经到期的成员并返回它们
+func (r *GuardRoster) Prune() []model.GuardMember {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	var expired []model.GuardMember
+	for uid, member := range r.members {
+		if !member.ExpireAt.IsZero() && member.ExpireAt.Before(now) {
+			expired = append(expired, *member)
+			delete(r.members, uid)
+		}
+	}
+
+	return expired
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/model"
+	"testing"
+	"time"
+)
+
+func TestGuardRosterSeedKeepsApplied(t *testing.T) {
+	roster := NewGuardRoster()
+	roster.Seed([]api.GuardInfo{{UserID: 1, UserName: "旧舰长", GuardLevel: 3}})
+
+	// 名单加载完成前收到的开通事件
+	roster.Apply(&model.GuardMessage{
+		UserID:     2,
+		UserName:   "新舰长",
+		GuardLevel: 3,
+		Num:        1,
+		Unit:       "月",
+		Timestamp:  time.Now(),
+	})
+
+	// 接口还没有包含新开通的成员，旧成员也已不在名单中
+	roster.Seed([]api.GuardInfo{{UserID: 3, UserName: "提督", GuardLevel: 2}})
+
+	if _, ok := roster.Get(1); ok {
+		t.Error("不在接口名单中且没有到期时间的成员应被移除")
+	}
+	member, ok := roster.Get(2)
+	if !ok {
+		t.Fatal("开通事件得到的成员被接口名单覆盖")
+	}
+	if member.ExpireAt.IsZero() {
+		t.Error("开通事件得到的到期时间丢失")
+	}
+	if _, ok := roster.Get(3); !ok {
+		t.Error("接口名单中的成员缺失")
+	}
+}
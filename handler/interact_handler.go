@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/parser"
+	"TianHe-API/protocol"
+)
+
+// InteractHandler 处理INTERACT_WORD和INTERACT_WORD_V2，按互动类型分别发布进房、关注和分享事件
+type InteractHandler struct {
+	emitter *event.Emitter
+}
+
+func NewInteractHandler(emitter *event.Emitter) *InteractHandler {
+	return &InteractHandler{emitter: emitter}
+}
+
+func (h *InteractHandler) Handle(msg *protocol.Message) error {
+	interact, err := parser.ParseInteract(msg)
+	if err != nil {
+		return err
+	}
+
+	switch interact.MsgType {
+	case model.InteractEnter:
+		h.emitter.Emit(event.TypeWelcome, &model.WelcomeMessage{
+			UserName:  interact.UserName,
+			UserID:    interact.UserID,
+			Timestamp: interact.Timestamp,
+			Medal:     interact.Medal,
+		})
+	case model.InteractFollow, model.InteractSpecialFollow, model.InteractMutualFollow:
+		h.emitter.Emit(event.TypeFollow, &model.FollowMessage{
+			UserName:  interact.UserName,
+			UserID:    interact.UserID,
+			Timestamp: interact.Timestamp,
+		})
+	case model.InteractShare:
+		h.emitter.Emit(event.TypeShare, &model.ShareMessage{
+			UserName:  interact.UserName,
+			UserID:    interact.UserID,
+			Timestamp: interact.Timestamp,
+		})
+	}
+	return nil
+}
+
+// LikeHandler 处理LIKE_INFO_V3_CLICK和LIKE_INFO_V3_UPDATE
+type LikeHandler struct {
+	emitter *event.Emitter
+}
+
+func NewLikeHandler(emitter *event.Emitter) *LikeHandler {
+	return &LikeHandler{emitter: emitter}
+}
+
+func (h *LikeHandler) Handle(msg *protocol.Message) error {
+	if msg.Cmd == protocol.CmdLikeUpdate {
+		count, err := parser.ParseLikeUpdate(msg)
+		if err != nil {
+			return err
+		}
+
+		h.emitter.Emit(event.TypeLikeCount, count)
+		return nil
+	}
+
+	like, err := parser.ParseLikeClick(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeLike, like)
+	return nil
+}
+
+type EntryEffectHandler struct {
+	emitter *event.Emitter
+}
+
+func NewEntryEffectHandler(emitter *event.Emitter) *EntryEffectHandler {
+	return &EntryEffectHandler{emitter: emitter}
+}
+
+func (h *EntryEffectHandler) Handle(msg *protocol.Message) error {
+	effect, err := parser.ParseEntryEffect(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeEntryEffect, effect)
+	return nil
+}
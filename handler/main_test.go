@@ -0,0 +1,12 @@
+package handler
+
+import (
+	"TianHe-API/utils"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
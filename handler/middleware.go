@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"TianHe-API/protocol"
+	"TianHe-API/utils"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Middleware 包裹消息处理器的中间件
+type Middleware func(next MessageHandler) MessageHandler
+
+// Chain 用中间件包裹处理器，第一个中间件在最外层
+func Chain(h MessageHandler, middlewares ...Middleware) MessageHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Logging 记录每条消息的处理耗时和错误
+func Logging() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return HandlerFunc(func(msg *protocol.Message) error {
+			start := time.Now()
+			err := next.Handle(msg)
+			if err != nil {
+				utils.Logger.Debugf("房间 %d 处理 %s 失败，耗时 %v: %v", msg.RoomID, msg.Cmd, time.Since(start), err)
+			} else {
+				utils.Logger.Debugf("房间 %d 处理 %s 完成，耗时 %v", msg.RoomID, msg.Cmd, time.Since(start))
+			}
+			return err
+		})
+	}
+}
+
+// Recovery 将处理器中的panic转为错误，避免读取协程退出
+func Recovery() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return HandlerFunc(func(msg *protocol.Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					utils.Logger.Errorf("房间 %d 处理 %s 时panic: %v\n%s", msg.RoomID, msg.Cmd, r, debug.Stack())
+					err = fmt.Errorf("处理 %s 时panic: %v", msg.Cmd, r)
+				}
+			}()
+			return next.Handle(msg)
+		})
+	}
+}
+
+// Filter 只处理fn返回true的消息，其余消息直接丢弃
+func Filter(fn func(msg *protocol.Message) bool) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return HandlerFunc(func(msg *protocol.Message) error {
+			if !fn(msg) {
+				return nil
+			}
+			return next.Handle(msg)
+		})
+	}
+}
+
+// RateLimit 按命令限制处理速率，每个命令每秒最多处理rate条，允许burst条突发，超出的消息被丢弃
+func RateLimit(rate float64, burst int) Middleware {
+	limiter := &rateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	return func(next MessageHandler) MessageHandler {
+		return HandlerFunc(func(msg *protocol.Message) error {
+			if !limiter.allow(msg.Cmd, time.Now()) {
+				return nil
+			}
+			return next.Handle(msg)
+		})
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mutex   sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+func (l *rateLimiter) allow(cmd string, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	bucket, ok := l.buckets[cmd]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[cmd] = bucket
+	}
+
+	// 按经过的时间补充令牌
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+	if bucket.tokens > l.burst {
+		bucket.tokens = l.burst
+	}
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// CmdMetrics 单个命令的处理统计
+type CmdMetrics struct {
+	Handled  uint64        `json:"handled"`  // 处理次数
+	Failed   uint64        `json:"failed"`   // 失败次数
+	Duration time.Duration `json:"duration"` // 累计耗时
+}
+
+// Metrics 按命令统计处理次数、失败次数和耗时
+type Metrics struct {
+	mutex sync.Mutex
+	cmds  map[string]*CmdMetrics
+}
+
+// NewMetrics 创建处理统计
+func NewMetrics() *Metrics {
+	return &Metrics{
+		cmds: make(map[string]*CmdMetrics),
+	}
+}
+
+// Middleware 返回记录统计的中间件
+func (m *Metrics) Middleware() Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return HandlerFunc(func(msg *protocol.Message) error {
+			start := time.Now()
+			err := next.Handle(msg)
+			m.record(msg.Cmd, time.Since(start), err)
+			return err
+		})
+	}
+}
+
+func (m *Metrics) record(cmd string, duration time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	stats, ok := m.cmds[cmd]
+	if !ok {
+		stats = &CmdMetrics{}
+		m.cmds[cmd] = stats
+	}
+
+	stats.Handled++
+	stats.Duration += duration
+	if err != nil {
+		stats.Failed++
+	}
+}
+
+// Snapshot 获取当前统计
+func (m *Metrics) Snapshot() map[string]CmdMetrics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := make(map[string]CmdMetrics, len(m.cmds))
+	for cmd, stats := range m.cmds {
+		snapshot[cmd] = *stats
+	}
+	return snapshot
+}
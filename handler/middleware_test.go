@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"TianHe-API/protocol"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecovery(t *testing.T) {
+	h := Chain(HandlerFunc(func(msg *protocol.Message) error {
+		panic("处理器出错")
+	}), Recovery())
+
+	err := h.Handle(&protocol.Message{Cmd: "DANMU_MSG", RoomID: 21452505})
+	if err == nil || !strings.Contains(err.Error(), "处理器出错") {
+		t.Errorf("err = %v", err)
+	}
+
+	// 没有panic时原样返回处理器的错误
+	want := errors.New("解析失败")
+	h = Chain(HandlerFunc(func(msg *protocol.Message) error {
+		return want
+	}), Recovery())
+	if err := h.Handle(&protocol.Message{Cmd: "DANMU_MSG"}); err != want {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var handled []string
+	h := Chain(HandlerFunc(func(msg *protocol.Message) error {
+		handled = append(handled, msg.Cmd)
+		return nil
+	}), Filter(func(msg *protocol.Message) bool {
+		return msg.Cmd != "WATCHED_CHANGE"
+	}))
+
+	for _, cmd := range []string{"DANMU_MSG", "WATCHED_CHANGE", "SEND_GIFT"} {
+		h.Handle(&protocol.Message{Cmd: cmd})
+	}
+	if strings.Join(handled, ",") != "DANMU_MSG,SEND_GIFT" {
+		t.Errorf("处理了 %v", handled)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	handled := make(map[string]int)
+	h := Chain(HandlerFunc(func(msg *protocol.Message) error {
+		handled[msg.Cmd]++
+		return nil
+	}), RateLimit(0.001, 2))
+
+	// 突发额度用完后丢弃，每个命令单独计数
+	for i := 0; i < 5; i++ {
+		if err := h.Handle(&protocol.Message{Cmd: "DANMU_MSG"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Handle(&protocol.Message{Cmd: "SEND_GIFT"})
+
+	if handled["DANMU_MSG"] != 2 || handled["SEND_GIFT"] != 1 {
+		t.Errorf("处理次数 = %v", handled)
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := &rateLimiter{rate: 2, burst: 2, buckets: make(map[string]*tokenBucket)}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !l.allow("DANMU_MSG", now) || !l.allow("DANMU_MSG", now) || l.allow("DANMU_MSG", now) {
+		t.Fatal("突发额度不是2")
+	}
+
+	// 每秒补充2个，半秒后只够1条
+	now = now.Add(500 * time.Millisecond)
+	if !l.allow("DANMU_MSG", now) || l.allow("DANMU_MSG", now) {
+		t.Error("半秒后应只补充1个令牌")
+	}
+
+	// 空闲再久也不超过突发额度
+	now = now.Add(time.Hour)
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if l.allow("DANMU_MSG", now) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("空闲后允许了 %d 条", allowed)
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"encoding/json"
+)
+
+// RawHandler 将没有对应处理器的消息原样发布，避免新命令被静默丢弃
+type RawHandler struct {
+	emitter *event.Emitter
+}
+
+func NewRawHandler(emitter *event.Emitter) *RawHandler {
+	return &RawHandler{emitter: emitter}
+}
+
+func (h *RawHandler) Handle(msg *protocol.Message) error {
+	h.emitter.Emit(event.TypeUnknown, &model.RawMessage{
+		Cmd:  msg.Cmd,
+		Data: json.RawMessage(msg.Raw),
+	})
+	return nil
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"TianHe-API/protocol"
+	"sync"
+)
+
+// HandlerFunc 以函数实现MessageHandler
+type HandlerFunc func(msg *protocol.Message) error
+
+func (f HandlerFunc) Handle(msg *protocol.Message) error {
+	return f(msg)
+}
+
+// Registry 消息处理器注册表，可以在运行中修改
+//
+// 注册到某个命令的处理器会取代内置处理器，中间件按注册顺序由外向内包裹处理器
+type Registry struct {
+	mutex       sync.RWMutex
+	handlers    map[string]MessageHandler
+	middlewares []Middleware
+	version     uint64 // 每次修改后增加，使用方据此判断是否需要重新包裹处理器
+}
+
+// NewRegistry 创建处理器注册表
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]MessageHandler),
+	}
+}
+
+// Handle 注册命令的处理器，已有的会被替换
+func (r *Registry) Handle(cmd string, h MessageHandler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.handlers[cmd] = h
+	r.version++
+}
+
+// HandleFunc 以函数注册命令的处理器
+func (r *Registry) HandleFunc(cmd string, fn func(msg *protocol.Message) error) {
+	r.Handle(cmd, HandlerFunc(fn))
+}
+
+// Remove 移除命令的处理器，恢复使用内置处理器
+func (r *Registry) Remove(cmd string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.handlers, cmd)
+	r.version++
+}
+
+// Use 追加中间件
+func (r *Registry) Use(middlewares ...Middleware) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.middlewares = append(r.middlewares, middlewares...)
+	r.version++
+}
+
+// Lookup 查找命令的处理器
+func (r *Registry) Lookup(cmd string) (MessageHandler, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	h, ok := r.handlers[cmd]
+	return h, ok
+}
+
+// Middlewares 获取已注册的中间件
+func (r *Registry) Middlewares() []Middleware {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	middlewares := make([]Middleware, len(r.middlewares))
+	copy(middlewares, r.middlewares)
+	return middlewares
+}
+
+// Version 获取注册表的版本，注册、移除处理器或追加中间件后改变
+func (r *Registry) Version() uint64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.version
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"TianHe-API/protocol"
+	"strings"
+	"testing"
+)
+
+// tagMiddleware 在处理前后记录自己的名字，用于检查包裹顺序
+func tagMiddleware(name string, trace *[]string) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return HandlerFunc(func(msg *protocol.Message) error {
+			*trace = append(*trace, name+">")
+			err := next.Handle(msg)
+			*trace = append(*trace, "<"+name)
+			return err
+		})
+	}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r := NewRegistry()
+	if _, ok := r.Lookup("DANMU_MSG"); ok {
+		t.Fatal("空注册表找到了处理器")
+	}
+
+	var handled []string
+	r.HandleFunc("DANMU_MSG", func(msg *protocol.Message) error {
+		handled = append(handled, "first")
+		return nil
+	})
+	r.HandleFunc("DANMU_MSG", func(msg *protocol.Message) error {
+		handled = append(handled, "second")
+		return nil
+	})
+
+	// 重复注册替换原有的处理器
+	h, ok := r.Lookup("DANMU_MSG")
+	if !ok {
+		t.Fatal("没有找到注册的处理器")
+	}
+	h.Handle(&protocol.Message{Cmd: "DANMU_MSG"})
+	if strings.Join(handled, ",") != "second" {
+		t.Errorf("处理器 = %v", handled)
+	}
+
+	r.Remove("DANMU_MSG")
+	if _, ok := r.Lookup("DANMU_MSG"); ok {
+		t.Error("移除后仍找到处理器")
+	}
+}
+
+func TestRegistryMiddlewareOrder(t *testing.T) {
+	r := NewRegistry()
+	var trace []string
+	r.Use(tagMiddleware("a", &trace), tagMiddleware("b", &trace))
+	r.Use(tagMiddleware("c", &trace))
+
+	// 先注册的中间件在外层
+	h := Chain(HandlerFunc(func(msg *protocol.Message) error {
+		trace = append(trace, "handler")
+		return nil
+	}), r.Middlewares()...)
+	h.Handle(&protocol.Message{Cmd: "DANMU_MSG"})
+
+	if got := strings.Join(trace, " "); got != "a> b> c> handler <c <b <a" {
+		t.Errorf("执行顺序 = %s", got)
+	}
+
+	// 返回的是副本，修改不影响注册表
+	middlewares := r.Middlewares()
+	middlewares[0] = nil
+	if r.Middlewares()[0] == nil {
+		t.Error("修改返回的中间件列表影响了注册表")
+	}
+}
+
+func TestRegistryVersion(t *testing.T) {
+	r := NewRegistry()
+	nop := HandlerFunc(func(msg *protocol.Message) error { return nil })
+
+	versions := []uint64{r.Version()}
+	r.Handle("DANMU_MSG", nop)
+	versions = append(versions, r.Version())
+	r.Use(Logging())
+	versions = append(versions, r.Version())
+	r.Remove("DANMU_MSG")
+	versions = append(versions, r.Version())
+
+	for i := 1; i < len(versions); i++ {
+		if versions[i] == versions[i-1] {
+			t.Errorf("第 %d 次修改后版本没有变化: %v", i, versions)
+		}
+	}
+
+	r.Lookup("DANMU_MSG")
+	r.Middlewares()
+	if r.Version() != versions[len(versions)-1] {
+		t.Error("查询改变了版本")
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/parser"
+	"TianHe-API/protocol"
+)
+
+type OnlineCountHandler struct {
+	emitter *event.Emitter
+}
+
+func NewOnlineCountHandler(emitter *event.Emitter) *OnlineCountHandler {
+	return &OnlineCountHandler{emitter: emitter}
+}
+
+func (h *OnlineCountHandler) Handle(msg *protocol.Message) error {
+	stats, err := parser.ParseOnlineCount(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeOnline, stats)
+	return nil
+}
+
+type WatchedHandler struct {
+	emitter *event.Emitter
+}
+
+func NewWatchedHandler(emitter *event.Emitter) *WatchedHandler {
+	return &WatchedHandler{emitter: emitter}
+}
+
+func (h *WatchedHandler) Handle(msg *protocol.Message) error {
+	watched, err := parser.ParseWatchedChange(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeWatched, watched)
+	return nil
+}
+
+// LiveStatusHandler 处理LIVE和PREPARING，同时开始、结束直播场次
+type LiveStatusHandler struct {
+	emitter  *event.Emitter
+	sessions *SessionTracker
+}
+
+func NewLiveStatusHandler(emitter *event.Emitter, sessions *SessionTracker) *LiveStatusHandler {
+	return &LiveStatusHandler{emitter: emitter, sessions: sessions}
+}
+
+func (h *LiveStatusHandler) Handle(msg *protocol.Message) error {
+	status, err := parser.ParseLiveStatus(msg)
+	if err != nil {
+		return err
+	}
+
+	// 开播事件属于新场次，下播事件属于结束的场次
+	if status.Live {
+		h.sessions.Live(status)
+		h.emitter.Emit(event.TypeLive, status)
+	} else {
+		h.emitter.Emit(event.TypePreparing, status)
+		h.sessions.Preparing(status)
+	}
+	return nil
+}
+
+type RoomChangeHandler struct {
+	emitter  *event.Emitter
+	sessions *SessionTracker
+}
+
+func NewRoomChangeHandler(emitter *event.Emitter, sessions *SessionTracker) *RoomChangeHandler {
+	return &RoomChangeHandler{emitter: emitter, sessions: sessions}
+}
+
+func (h *RoomChangeHandler) Handle(msg *protocol.Message) error {
+	change, err := parser.ParseRoomChange(msg)
+	if err != nil {
+		return err
+	}
+
+	h.sessions.RoomChange(change)
+	h.emitter.Emit(event.TypeRoomChange, change)
+	return nil
+}
+
+type RoomBlockHandler struct {
+	emitter *event.Emitter
+}
+
+func NewRoomBlockHandler(emitter *event.Emitter) *RoomBlockHandler {
+	return &RoomBlockHandler{emitter: emitter}
+}
+
+func (h *RoomBlockHandler) Handle(msg *protocol.Message) error {
+	block, err := parser.ParseRoomBlock(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeBlock, block)
+	return nil
+}
+
+// WarningHandler 处理WARNING和CUT_OFF
+type WarningHandler struct {
+	emitter *event.Emitter
+}
+
+func NewWarningHandler(emitter *event.Emitter) *WarningHandler {
+	return &WarningHandler{emitter: emitter}
+}
+
+func (h *WarningHandler) Handle(msg *protocol.Message) error {
+	warning, err := parser.ParseWarning(msg)
+	if err != nil {
+		return err
+	}
+
+	if warning.CutOff {
+		h.emitter.Emit(event.TypeCutOff, warning)
+	} else {
+		h.emitter.Emit(event.TypeWarning, warning)
+	}
+	return nil
+}
+
+type OnlineRankHandler struct {
+	emitter *event.Emitter
+}
+
+func NewOnlineRankHandler(emitter *event.Emitter) *OnlineRankHandler {
+	return &OnlineRankHandler{emitter: emitter}
+}
+
+func (h *OnlineRankHandler) Handle(msg *protocol.Message) error {
+	rank, err := parser.ParseOnlineRank(msg)
+	if err != nil {
+		return err
+	}
+
+	h.emitter.Emit(event.TypeOnlineRank, rank)
+	return nil
+}
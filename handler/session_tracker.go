@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SessionTracker 跟踪直播间的开播状态，把事件按直播场次分组
+//
+// 开播时发布TypeSessionStart并让之后的事件都带上场次ID，下播时发布TypeSessionEnd。
+// 状态来自LIVE、PREPARING、ROOM_CHANGE消息，也可以由定时拉取的房间信息补充。
+// 断流后以相同live_key重新开播时继续上一场，不算新的场次
+type SessionTracker struct {
+	emitter *event.Emitter
+	mutex   sync.Mutex
+	current *model.LiveSession
+	last    *model.LiveSession // 上一场，下播后接口还会短暂返回直播中，断流重连时也要继续这一场
+	resumed time.Time          // 当前场次断流后重新开播的时间，接口返回的开播时间可能是这个时间
+
+	// 串行化场次变化和事件发布，保证SetSession与事件的顺序一致。发布事件时不持有mutex，
+	// 订阅者可以在回调中查询当前场次
+	changeMutex sync.Mutex
+
+	// 最近一次已知的房间信息，开播时用来填充场次
+	title          string
+	areaName       string
+	parentAreaName string
+	cover          string
+}
+
+// NewSessionTracker 创建直播场次跟踪器
+func NewSessionTracker(emitter *event.Emitter) *SessionTracker {
+	return &SessionTracker{emitter: emitter}
+}
+
+// Current 获取当前直播场次，未开播时返回nil
+func (t *SessionTracker) Current() *model.LiveSession {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.current == nil {
+		return nil
+	}
+	session := *t.current
+	return &session
+}
+
+// ID 获取当前直播场次ID，未开播时为空
+func (t *SessionTracker) ID() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.current == nil {
+		return ""
+	}
+	return t.current.ID
+}
+
+// sessionChange 一次状态变化中结束和开始的场次，在释放锁之后发布
+type sessionChange struct {
+	ended   *model.LiveSession
+	started *model.LiveSession
+}
+
+// Live 收到开播消息，已在直播中时只补全live_key，live_key与上一场相同时继续上一场
+func (t *SessionTracker) Live(status *model.LiveStatusMessage) {
+	t.changeMutex.Lock()
+	defer t.changeMutex.Unlock()
+
+	var change sessionChange
+	t.mutex.Lock()
+	if t.current != nil {
+		if t.current.LiveKey == "" {
+			t.current.LiveKey = status.LiveKey
+		}
+	} else if status.LiveKey != "" && t.last != nil && t.last.LiveKey == status.LiveKey {
+		change.started = t.resume(status.Timestamp)
+	} else {
+		// 开播时会连续下发两条LIVE，只有其中一条带live_time
+		startTime := status.LiveTime
+		if startTime.IsZero() {
+			startTime = status.Timestamp
+		}
+		change.started = t.start(startTime, status.LiveKey)
+	}
+	t.mutex.Unlock()
+
+	t.publish(change)
+}
+
+// Preparing 收到下播消息
+func (t *SessionTracker) Preparing(status *model.LiveStatusMessage) {
+	t.changeMutex.Lock()
+	defer t.changeMutex.Unlock()
+
+	t.mutex.Lock()
+	change := sessionChange{ended: t.end(status.Timestamp)}
+	t.mutex.Unlock()
+
+	t.publish(change)
+}
+
+// RoomChange 收到房间信息变更，更新当前场次的标题和分区
+func (t *SessionTracker) RoomChange(change *model.RoomChangeMessage) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.title = change.Title
+	t.areaName = change.AreaName
+	t.parentAreaName = change.ParentAreaName
+	if t.current != nil {
+		t.current.Title = change.Title
+		t.current.AreaName = change.AreaName
+		t.current.ParentAreaName = change.ParentAreaName
+	}
+}
+
+// Observe 用拉取到的房间信息校正开播状态，可以补上连接前就已开播或断线期间漏掉的开播、下播
+func (t *SessionTracker) Observe(info *api.RoomInfo) {
+	t.changeMutex.Lock()
+	defer t.changeMutex.Unlock()
+
+	t.mutex.Lock()
+	change := t.observe(info)
+	t.mutex.Unlock()
+
+	t.publish(change)
+}
+
+// 调用方需持有锁
+func (t *SessionTracker) observe(info *api.RoomInfo) sessionChange {
+	var change sessionChange
+
+	t.title = info.Title
+	t.areaName = info.AreaName
+	t.parentAreaName = info.ParentAreaName
+	t.cover = info.Cover
+
+	// 轮播不算直播
+	live := info.LiveStatus == 1
+	if t.current != nil && (!live || t.restartedSince(info.LiveTime)) {
+		// 已下播，或者断线期间下播后又重新开播
+		change.ended = t.end(time.Now())
+	}
+
+	if t.current != nil {
+		t.current.Title = info.Title
+		t.current.AreaName = info.AreaName
+		t.current.ParentAreaName = info.ParentAreaName
+		t.current.Cover = info.Cover
+		return change
+	}
+
+	if live && !t.sameAsEnded(info.LiveTime) {
+		startTime := info.LiveTime
+		if startTime.IsZero() {
+			startTime = time.Now()
+		}
+		change.started = t.start(startTime, "")
+	}
+	return change
+}
+
+// 开播时间与上一场相差不到一分钟时认为是同一场，调用方需持有锁
+func (t *SessionTracker) sameAsEnded(liveTime time.Time) bool {
+	if t.last == nil || liveTime.IsZero() {
+		return false
+	}
+	return liveTime.Before(t.last.StartTime.Add(time.Minute))
+}
+
+// 接口返回的开播时间比当前场次的开播和断流重连都晚一分钟以上时，说明断线期间下播后又开了新的一场，调用方需持有锁
+func (t *SessionTracker) restartedSince(liveTime time.Time) bool {
+	if liveTime.IsZero() {
+		return false
+	}
+	known := t.current.StartTime
+	if t.resumed.After(known) {
+		known = t.resumed
+	}
+	return known.Before(liveTime.Add(-time.Minute))
+}
+
+// publish 设置事件的场次并发布场次变化，调用方需持有changeMutex，不能持有mutex
+func (t *SessionTracker) publish(change sessionChange) {
+	if change.ended != nil {
+		// 结束事件仍属于这一场
+		t.emitter.Emit(event.TypeSessionEnd, change.ended)
+		t.emitter.SetSession("")
+	}
+	if change.started != nil {
+		t.emitter.SetSession(change.started.ID)
+		t.emitter.Emit(event.TypeSessionStart, change.started)
+	}
+}
+
+// start 开始新的场次，返回要发布的副本，调用方需持有锁
+func (t *SessionTracker) start(startTime time.Time, liveKey string) *model.LiveSession {
+	t.current = &model.LiveSession{
+		ID:             fmt.Sprintf("%d-%d", t.emitter.RoomID(), startTime.Unix()),
+		RoomID:         t.emitter.RoomID(),
+		LiveKey:        liveKey,
+		Title:          t.title,
+		AreaName:       t.areaName,
+		ParentAreaName: t.parentAreaName,
+		Cover:          t.cover,
+		StartTime:      startTime,
+	}
+	t.resumed = time.Time{}
+
+	session := *t.current
+	return &session
+}
+
+// resume 断流后重新开播，继续上一场，返回要发布的副本，调用方需持有锁
+func (t *SessionTracker) resume(resumedAt time.Time) *model.LiveSession {
+	t.current = t.last
+	t.current.EndTime = time.Time{}
+	t.last = nil
+	t.resumed = resumedAt
+
+	session := *t.current
+	return &session
+}
+
+// end 结束当前场次，返回要发布的副本，未开播时返回nil，调用方需持有锁
+func (t *SessionTracker) end(endTime time.Time) *model.LiveSession {
+	if t.current == nil {
+		return nil
+	}
+
+	t.current.EndTime = endTime
+	t.last = t.current
+	t.current = nil
+
+	session := *t.last
+	return &session
+}
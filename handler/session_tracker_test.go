@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"TianHe-API/api"
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"testing"
+	"time"
+)
+
+// newTestTracker 创建场次跟踪器并收集场次事件，回调中查询当前场次以确认发布时不持有锁
+func newTestTracker(t *testing.T) (*SessionTracker, *[]*event.Event) {
+	bus := event.NewBus()
+	tracker := NewSessionTracker(bus.Emitter(21452505, 0))
+
+	var events []*event.Event
+	bus.SubscribeFunc(event.Filter{Types: []event.Type{event.TypeSessionStart, event.TypeSessionEnd}}, func(e *event.Event) {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			tracker.Current()
+			tracker.ID()
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("发布场次事件时仍持有锁")
+		}
+		events = append(events, e)
+	})
+	return tracker, &events
+}
+
+var liveTime = time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+func TestSessionLivePreparing(t *testing.T) {
+	tracker, events := newTestTracker(t)
+
+	tracker.Live(&model.LiveStatusMessage{Live: true, LiveKey: "key1", LiveTime: liveTime, Timestamp: liveTime})
+	// 开播时的第二条LIVE不会开始新的场次
+	tracker.Live(&model.LiveStatusMessage{Live: true, Timestamp: liveTime.Add(time.Second)})
+
+	current := tracker.Current()
+	if current == nil || current.ID != "21452505-1704139200" || current.LiveKey != "key1" {
+		t.Fatalf("当前场次 = %+v", current)
+	}
+
+	tracker.Preparing(&model.LiveStatusMessage{Timestamp: liveTime.Add(time.Hour)})
+	if tracker.Current() != nil || tracker.ID() != "" {
+		t.Error("下播后仍有当前场次")
+	}
+
+	if len(*events) != 2 {
+		t.Fatalf("收到 %d 个场次事件", len(*events))
+	}
+	start, end := (*events)[0], (*events)[1]
+	if start.Type != event.TypeSessionStart || start.SessionID != current.ID {
+		t.Errorf("开始事件 = %+v", start)
+	}
+	ended, _ := end.Session()
+	if end.Type != event.TypeSessionEnd || end.SessionID != current.ID || !ended.EndTime.Equal(liveTime.Add(time.Hour)) {
+		t.Errorf("结束事件 = %+v", end)
+	}
+}
+
+func TestSessionObserveSeeds(t *testing.T) {
+	tracker, events := newTestTracker(t)
+
+	info := &api.RoomInfo{
+		LiveStatus:     1,
+		LiveTime:       liveTime,
+		Title:          "测试直播",
+		AreaName:       "单机游戏",
+		ParentAreaName: "游戏",
+		Cover:          "https://i0.hdslb.com/cover.jpg",
+	}
+	tracker.Observe(info)
+	tracker.Observe(info)
+
+	current := tracker.Current()
+	if current == nil || !current.StartTime.Equal(liveTime) || current.Title != "测试直播" || current.Cover != info.Cover {
+		t.Fatalf("当前场次 = %+v", current)
+	}
+	if len(*events) != 1 {
+		t.Fatalf("收到 %d 个场次事件", len(*events))
+	}
+
+	// 下播后接口还短暂返回直播中，不能再开一场
+	info.LiveStatus = 0
+	tracker.Observe(info)
+	info.LiveStatus = 1
+	tracker.Observe(info)
+	if tracker.Current() != nil || len(*events) != 2 {
+		t.Errorf("下播后又开始了场次，事件数 %d", len(*events))
+	}
+
+	// 轮播不算直播
+	info.LiveStatus = 2
+	info.LiveTime = liveTime.Add(2 * time.Hour)
+	tracker.Observe(info)
+	if tracker.Current() != nil {
+		t.Error("轮播时开始了场次")
+	}
+}
+
+func TestSessionResumeAfterOutage(t *testing.T) {
+	tracker, events := newTestTracker(t)
+
+	tracker.Live(&model.LiveStatusMessage{Live: true, LiveKey: "key1", LiveTime: liveTime, Timestamp: liveTime})
+	id := tracker.ID()
+	tracker.Preparing(&model.LiveStatusMessage{Timestamp: liveTime.Add(time.Hour)})
+
+	// 断流后以相同的live_key重新开播
+	restart := liveTime.Add(time.Hour + 2*time.Minute)
+	tracker.Live(&model.LiveStatusMessage{Live: true, LiveKey: "key1", LiveTime: restart, Timestamp: restart})
+	current := tracker.Current()
+	if current == nil || current.ID != id || !current.StartTime.Equal(liveTime) || !current.EndTime.IsZero() {
+		t.Fatalf("没有继续上一场: %+v", current)
+	}
+
+	// 接口返回重新开播的时间时仍是同一场
+	tracker.Observe(&api.RoomInfo{LiveStatus: 1, LiveTime: restart})
+	if tracker.ID() != id {
+		t.Errorf("房间信息把继续的场次当成了新的一场")
+	}
+
+	// 不同的live_key是新的一场
+	tracker.Preparing(&model.LiveStatusMessage{Timestamp: restart.Add(time.Hour)})
+	next := restart.Add(2 * time.Hour)
+	tracker.Live(&model.LiveStatusMessage{Live: true, LiveKey: "key2", LiveTime: next, Timestamp: next})
+	if tracker.ID() == id {
+		t.Error("不同live_key仍继续上一场")
+	}
+
+	if len(*events) != 5 {
+		t.Errorf("收到 %d 个场次事件", len(*events))
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SuperChatTracker 跟踪直播间中正在展示的醒目留言
+//
+// 醒目留言被删除或到达结束时间后移出列表，到期时发布TypeSuperChatExpire事件
+type SuperChatTracker struct {
+	emitter *event.Emitter
+	mutex   sync.Mutex
+	active  map[int64]*trackedSuperChat
+}
+
+type trackedSuperChat struct {
+	superChat *model.SuperChatMessage
+	timer     *time.Timer
+}
+
+// NewSuperChatTracker 创建醒目留言跟踪器
+func NewSuperChatTracker(emitter *event.Emitter) *SuperChatTracker {
+	return &SuperChatTracker{
+		emitter: emitter,
+		active:  make(map[int64]*trackedSuperChat),
+	}
+}
+
+// Add 开始跟踪醒目留言，同一条留言会以不同命令重复下发，重复时只补全缺失的字段并返回false
+func (t *SuperChatTracker) Add(superChat *model.SuperChatMessage) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if tracked, ok := t.active[superChat.ID]; ok {
+		if tracked.superChat.MessageTrans == "" {
+			tracked.superChat.MessageTrans = superChat.MessageTrans
+		}
+		if tracked.superChat.BackgroundColor == "" {
+			tracked.superChat.BackgroundColor = superChat.BackgroundColor
+		}
+		return false
+	}
+
+	remaining := time.Until(superChat.EndTime)
+	if remaining <= 0 {
+		return true
+	}
+
+	// 保存副本，补全字段时不修改已发布出去的事件数据
+	copied := *superChat
+	tracked := &trackedSuperChat{superChat: &copied}
+	tracked.timer = time.AfterFunc(remaining, func() {
+		t.expire(copied.ID, tracked)
+	})
+	t.active[superChat.ID] = tracked
+	return true
+}
+
+// Delete 移除被删除的醒目留言
+func (t *SuperChatTracker) Delete(ids []int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, id := range ids {
+		if tracked, ok := t.active[id]; ok {
+			tracked.timer.Stop()
+			delete(t.active, id)
+		}
+	}
+}
+
+// expire 到期移除，留言已被删除时忽略
+func (t *SuperChatTracker) expire(id int64, tracked *trackedSuperChat) {
+	t.mutex.Lock()
+	if t.active[id] != tracked {
+		t.mutex.Unlock()
+		return
+	}
+	delete(t.active, id)
+	t.mutex.Unlock()
+
+	t.emitter.Emit(event.TypeSuperChatExpire, tracked.superChat)
+}
+
+// List 获取正在展示的醒目留言，按开始时间从早到晚排序
+func (t *SuperChatTracker) List() []model.SuperChatMessage {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	list := make([]model.SuperChatMessage, 0, len(t.active))
+	for _, tracked := range t.active {
+		list = append(list, *tracked.superChat)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].StartTime.Equal(list[j].StartTime) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].StartTime.Before(list[j].StartTime)
+	})
+
+	return list
+}
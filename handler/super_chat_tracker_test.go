@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestSuperChats 创建醒目留言处理器，返回按类型收集的事件
+func newTestSuperChats() (*SuperChatTracker, *SuperChatHandler, *SuperChatDeleteHandler, func(event.Type) []*event.Event) {
+	bus := event.NewBus()
+	var mutex sync.Mutex
+	var events []*event.Event
+	bus.SubscribeFunc(event.Filter{}, func(e *event.Event) {
+		mutex.Lock()
+		events = append(events, e)
+		mutex.Unlock()
+	})
+
+	emitter := bus.Emitter(21452505, 0)
+	tracker := NewSuperChatTracker(emitter)
+	return tracker, NewSuperChatHandler(emitter, tracker), NewSuperChatDeleteHandler(emitter, tracker), func(t event.Type) []*event.Event {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		var list []*event.Event
+		for _, e := range events {
+			if e.Type == t {
+				list = append(list, e)
+			}
+		}
+		return list
+	}
+}
+
+// superChatBody 从现在开始展示一小时的醒目留言
+func superChatBody(cmd string, id int64, extra string) string {
+	now := time.Now().Unix()
+	return fmt.Sprintf(`{"cmd":"%s","data":{"id":%d,"uid":12345678,"user_info":{"uname":"测试观众"},"message":"主播加油","price":30,"time":3600,"start_time":%d,"end_time":%d%s}}`,
+		cmd, id, now, now+3600, extra)
+}
+
+func TestSuperChatMergeJPN(t *testing.T) {
+	tracker, h, _, events := newTestSuperChats()
+
+	// 同一条留言先后以两种命令下发，只发布一次，翻译由JPN补全
+	if err := h.Handle(parseMessage(t, superChatBody("SUPER_CHAT_MESSAGE", 8765432, `,"background_color":"#EDF5FF"`))); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Handle(parseMessage(t, superChatBody("SUPER_CHAT_MESSAGE_JPN", 8765432, `,"message_jpn":"配信者頑張って"`))); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := events(event.TypeSuperChat); len(got) != 1 {
+		t.Fatalf("发布了 %d 次", len(got))
+	}
+	list := tracker.List()
+	if len(list) != 1 || list[0].MessageTrans != "配信者頑張って" || list[0].BackgroundColor != "#EDF5FF" {
+		t.Errorf("列表 = %+v", list)
+	}
+
+	// 已发布的事件数据不被补全修改
+	published, _ := events(event.TypeSuperChat)[0].SuperChat()
+	if published.MessageTrans != "" {
+		t.Errorf("补全字段修改了已发布的事件: %+v", published)
+	}
+}
+
+func TestSuperChatDelete(t *testing.T) {
+	tracker, h, deleteHandler, events := newTestSuperChats()
+
+	for _, id := range []int64{1, 2, 3} {
+		if err := h.Handle(parseMessage(t, superChatBody("SUPER_CHAT_MESSAGE", id, ""))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := deleteHandler.Handle(parseMessage(t, `{"cmd":"SUPER_CHAT_MESSAGE_DELETE","data":{"ids":[1,3,404]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := tracker.List()
+	if len(list) != 1 || list[0].ID != 2 {
+		t.Errorf("删除后的列表 = %+v", list)
+	}
+	if got := events(event.TypeSuperChatDelete); len(got) != 1 {
+		t.Errorf("删除事件 %d 个", len(got))
+	}
+	if got := events(event.TypeSuperChatExpire); len(got) != 0 {
+		t.Errorf("被删除的留言发布了到期事件")
+	}
+}
+
+func TestSuperChatExpire(t *testing.T) {
+	tracker, _, _, events := newTestSuperChats()
+
+	now := time.Now()
+	tracker.Add(&model.SuperChatMessage{ID: 1, StartTime: now, EndTime: now.Add(30 * time.Millisecond)})
+	tracker.Add(&model.SuperChatMessage{ID: 2, StartTime: now, EndTime: now.Add(time.Hour)})
+	// 已过结束时间的留言仍然发布，但不跟踪
+	if !tracker.Add(&model.SuperChatMessage{ID: 3, StartTime: now.Add(-time.Hour), EndTime: now.Add(-time.Minute)}) {
+		t.Error("已结束的留言应返回true")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(events(event.TypeSuperChatExpire)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("到达结束时间后没有发布到期事件")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	expired, _ := events(event.TypeSuperChatExpire)[0].SuperChat()
+	if expired.ID != 1 {
+		t.Errorf("到期的留言 = %d", expired.ID)
+	}
+	if list := tracker.List(); len(list) != 1 || list[0].ID != 2 {
+		t.Errorf("到期后的列表 = %+v", list)
+	}
+}
+
+func TestSuperChatListOrder(t *testing.T) {
+	tracker, _, _, _ := newTestSuperChats()
+
+	base := time.Now()
+	end := base.Add(time.Hour)
+	tracker.Add(&model.SuperChatMessage{ID: 5, StartTime: base.Add(2 * time.Second), EndTime: end})
+	tracker.Add(&model.SuperChatMessage{ID: 9, StartTime: base, EndTime: end})
+	tracker.Add(&model.SuperChatMessage{ID: 3, StartTime: base.Add(time.Second), EndTime: end})
+	tracker.Add(&model.SuperChatMessage{ID: 7, StartTime: base, EndTime: end})
+
+	// 按开始时间排序，同时开始的按ID排序
+	var ids []int64
+	for _, superChat := range tracker.List() {
+		ids = append(ids, superChat.ID)
+	}
+	if fmt.Sprint(ids) != "[7 9 3 5]" {
+		t.Errorf("顺序 = %v", ids)
+	}
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"TianHe-API/archive"
 	"TianHe-API/auth"
 	"TianHe-API/client"
 	"TianHe-API/config"
+	"TianHe-API/event"
+	"TianHe-API/handler"
+	"TianHe-API/parser"
+	"TianHe-API/store"
 	"TianHe-API/utils"
+	"context"
+	"errors"
 	"fmt"
-	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -19,10 +25,14 @@ func main() {
 	// 读取配置
 	cfg := config.NewConfig()
 
+	// 收到退出信号时取消ctx
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// 检查登录状态
-	if !auth.IsLoggedIn() {
+	if !auth.IsLoggedIn(ctx) {
 		utils.Logger.Info("未检测到有效登录状态，开始扫码登录...")
-		err := auth.QRCodeLogin()
+		err := auth.QRCodeLogin(ctx)
 		if err != nil {
 			utils.Logger.Fatalf("登录失败: %v", err)
 		}
@@ -34,10 +44,46 @@ func main() {
 	// 创建客户端管理器
 	manager := client.NewManager(cfg)
 
+	// 在控制台打印事件
+	if cfg.Console {
+		manager.SubscribeFunc(event.Filter{}, handler.PrintEvent)
+	}
+
+	// 归档所有事件
+	var sink *archive.Sink
+	if cfg.ArchiveDir != "" {
+		sink = archive.NewSink(archive.Options{
+			Dir:          cfg.ArchiveDir,
+			MaxSize:      int64(cfg.ArchiveMaxSize) << 20,
+			MaxAge:       time.Duration(cfg.ArchiveRotateAfter) * time.Minute,
+			SyncInterval: time.Duration(cfg.ArchiveSyncInterval) * time.Second,
+			Compress:     cfg.ArchiveCompress,
+		})
+		manager.SubscribeFunc(event.Filter{}, sink.Handle)
+	}
+
+	// 存储可查询的历史记录
+	var db *store.Store
+	if cfg.StorePath != "" {
+		var err error
+		db, err = store.Open(ctx, store.Options{
+			Path:          cfg.StorePath,
+			BatchSize:     cfg.StoreBatchSize,
+			FlushInterval: time.Duration(cfg.StoreFlushInterval) * time.Second,
+		})
+		if err != nil {
+			utils.Logger.Errorf("打开数据库失败，不存储历史记录: %v", err)
+		} else {
+			manager.SubscribeFunc(event.Filter{}, db.Handle)
+		}
+	}
+
 	// 添加要监听的房间
 	for _, roomID := range cfg.RoomIDs {
-		err := manager.AddRoom(roomID)
-		if err != nil {
+		err := manager.AddRoom(ctx, roomID)
+		if errors.Is(err, client.ErrRoomExists) {
+			utils.Logger.Infof("房间 %d 已在监听列表中，跳过", roomID)
+		} else if err != nil {
 			utils.Logger.Errorf("添加房间 %d 失败: %v", roomID, err)
 		} else {
 			utils.Logger.Infof("开始监听房间 %d", roomID)
@@ -45,29 +91,58 @@ func main() {
 	}
 
 	// 启动监听
-	manager.Start()
+	manager.Start(ctx)
 
-	fmt.Printf("开始监听 %d 个直播间...\n", len(cfg.RoomIDs))
+	fmt.Printf("开始监听 %d 个直播间...\n", len(manager.GetRooms()))
 
 	// 定期检查登录状态
 	go func() {
 		ticker := time.NewTicker(10 * time.Minute)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			if !auth.IsLoggedIn() {
-				utils.Logger.Warn("登录状态失效，请重新登录")
-				manager.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !auth.IsLoggedIn(ctx) {
+					utils.Logger.Warn("登录状态失效，请重新登录")
+					stop()
+					return
+				}
+				logParseFailures()
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
 	// 等待退出信号
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-	<-c
+	<-ctx.Done()
 
 	fmt.Println("正在关闭...")
-	manager.Stop()
+	logParseFailures()
+	stopCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := manager.Stop(stopCtx); err != nil {
+		utils.Logger.Errorf("关闭超时: %v", err)
+	}
+
+	// 管理器关闭时会结算剩余的礼物连击，归档要在它之后关闭
+	if sink != nil {
+		if err := sink.Close(); err != nil {
+			utils.Logger.Errorf("关闭归档失败: %v", err)
+		}
+	}
+	if db != nil {
+		if err := db.Close(); err != nil {
+			utils.Logger.Errorf("关闭数据库失败: %v", err)
+		}
+	}
+}
+
+// 输出各命令的解析失败次数，次数多说明服务器改了消息结构
+func logParseFailures() {
+	counts := parser.FailureCounts()
+	for _, cmd := range parser.FailedCmds() {
+		utils.Logger.Warnf("命令 %s 累计解析失败 %d 次", cmd, counts[cmd])
+	}
 }
@@ -0,0 +1,18 @@
+package model
+
+// 货币类型
+const (
+	CoinGold   = "gold"   // 金瓜子，付费，1元=1000金瓜子=10电池
+	CoinSilver = "silver" // 银瓜子，免费获得，没有人民币价值
+)
+
+// GoldPerCNY 每元人民币对应的金瓜子数
+const GoldPerCNY = 1000
+
+// CoinToCNY 把瓜子数换算为人民币元，银瓜子和未知类型返回0
+func CoinToCNY(coinType string, coins int64) float64 {
+	if coinType != CoinGold {
+		return 0
+	}
+	return float64(coins) / GoldPerCNY
+}
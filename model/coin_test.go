@@ -0,0 +1,24 @@
+package model
+
+import "testing"
+
+func TestCoinToCNY(t *testing.T) {
+	tests := []struct {
+		coinType string
+		coins    int64
+		want     float64
+	}{
+		{CoinGold, 1000, 1},
+		{CoinGold, 100, 0.1},
+		{CoinGold, 198000, 198},
+		{CoinGold, 0, 0},
+		{CoinSilver, 1000, 0},
+		{"", 1000, 0},
+	}
+
+	for _, tt := range tests {
+		if got := CoinToCNY(tt.coinType, tt.coins); got != tt.want {
+			t.Errorf("CoinToCNY(%q, %d) = %v, want %v", tt.coinType, tt.coins, got, tt.want)
+		}
+	}
+}
@@ -2,14 +2,76 @@ package model
 
 import "time"
 
+// 弹幕类型
+const (
+	DmTypeText     = 0 // 普通文字弹幕
+	DmTypeEmoticon = 1 // 表情包弹幕
+)
+
+// 大航海等级
+const (
+	GuardLevelNone     = 0
+	GuardLevelGovernor = 1 // 总督
+	GuardLevelAdmiral  = 2 // 提督
+	GuardLevelCaptain  = 3 // 舰长
+)
+
 // 弹幕消息
 type DanmuMessage struct {
 	Text      string    `json:"text"`
 	UserName  string    `json:"user_name"`
 	UserID    int64     `json:"user_id"`
-	Timestamp time.Time `json:"timestamp"`
+	Timestamp time.Time `json:"timestamp"` // 服务器发送时间，缺失时为接收时间
 	Color     string    `json:"color"`
 	FontSize  int       `json:"font_size"`
+
+	Mode       int       `json:"mode"`               // 弹幕模式：1滚动 4底部 5顶部
+	DmType     int       `json:"dm_type"`            // 弹幕类型，见DmType常量
+	Emoticon   *Emoticon `json:"emoticon,omitempty"` // 表情包弹幕的表情
+	Emots      []Emot    `json:"emots,omitempty"`    // 文字中内嵌的小表情
+	IsAdmin    bool      `json:"is_admin"`           // 是否为房管
+	UserLevel  int       `json:"user_level"`         // 用户直播等级(UL)
+	GuardLevel int       `json:"guard_level"`        // 大航海等级，见GuardLevel常量
+	Medal      *FanMedal `json:"medal,omitempty"`    // 佩戴的粉丝勋章
+	ReplyTo    *ReplyTo  `json:"reply_to,omitempty"` // 回复的用户
+	IDStr      string    `json:"id_str,omitempty"`   // 弹幕唯一ID，撤回时用于定位
+	ReceivedAt time.Time `json:"received_at"`        // 本地接收时间
+}
+
+// IsEmoticonOnly 是否为纯表情包弹幕
+func (d *DanmuMessage) IsEmoticonOnly() bool {
+	return d.DmType == DmTypeEmoticon
+}
+
+// 粉丝勋章
+type FanMedal struct {
+	Name         string `json:"name"`
+	Level        int    `json:"level"`
+	AnchorName   string `json:"anchor_name"`
+	AnchorRoomID int    `json:"anchor_room_id"`
+	AnchorUID    int64  `json:"anchor_uid"`
+	GuardLevel   int    `json:"guard_level"` // 在勋章所属直播间的大航海等级
+	Lighted      bool   `json:"lighted"`     // 勋章是否点亮
+}
+
+// 表情包
+type Emoticon struct {
+	Unique string `json:"unique"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// 文字中内嵌的小表情
+type Emot struct {
+	Descript string `json:"descript"` // 在文字中的占位文本，如[dog]
+	Emoticon
+}
+
+// 被回复的用户
+type ReplyTo struct {
+	UserID   int64  `json:"user_id"`
+	UserName string `json:"user_name"`
 }
 
 // 礼物消息
@@ -19,8 +81,43 @@ type GiftMessage struct {
 	UserName  string    `json:"user_name"`
 	UserID    int64     `json:"user_id"`
 	Num       int       `json:"num"`
-	Price     int       `json:"price"`
+	Price     int       `json:"price"` // 单价，单位由CoinType决定
 	Timestamp time.Time `json:"timestamp"`
+
+	BatchComboID string  `json:"batch_combo_id,omitempty"` // 连击批次ID，同一次连击的礼物相同
+	CoinType     string  `json:"coin_type"`                // gold或silver，见Coin常量
+	TotalPrice   int64   `json:"total_price"`              // 总价，单位同Price
+	CNY          float64 `json:"cny"`                      // 折合人民币元，银瓜子礼物为0
+	GiftIcon     string  `json:"gift_icon,omitempty"`      // 礼物图标，来自礼物配置缓存
+}
+
+// 连击汇总，来自COMBO_SEND
+type ComboMessage struct {
+	BatchComboID string    `json:"batch_combo_id"`
+	GiftName     string    `json:"gift_name"`
+	GiftID       int       `json:"gift_id"`
+	UserName     string    `json:"user_name"`
+	UserID       int64     `json:"user_id"`
+	TotalNum     int       `json:"total_num"`  // 本次连击累计数量
+	TotalCoin    int64     `json:"total_coin"` // 本次连击累计价值
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// 合并后的礼物，一次连击只结算一次
+type GiftSettledMessage struct {
+	BatchComboID string    `json:"batch_combo_id,omitempty"` // 没有连击ID的礼物单独结算
+	GiftName     string    `json:"gift_name"`
+	GiftID       int       `json:"gift_id"`
+	UserName     string    `json:"user_name"`
+	UserID       int64     `json:"user_id"`
+	Num          int       `json:"num"`        // 合计数量
+	Value        int64     `json:"value"`      // 合计价值，单位由CoinType决定
+	CoinType     string    `json:"coin_type"`  // gold或silver
+	CNY          float64   `json:"cny"`        // 合计折合人民币元
+	Sends        int       `json:"sends"`      // 合并的SEND_GIFT条数
+	Reconciled   bool      `json:"reconciled"` // 合计是否按COMBO_SEND校正过
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
 }
 
 // 进房消息
@@ -29,6 +126,9 @@ type WelcomeMessage struct {
 	UserID    int64     `json:"user_id"`
 	Timestamp time.Time `json:"timestamp"`
 	IsVip     bool      `json:"is_vip"`
+	Medal     *FanMedal `json:"medal,omitempty"` // 佩戴的粉丝勋章
+
+	GuardLevel int `json:"guard_level,omitempty"` // 大航海等级，仅WELCOME_GUARD有
 }
 
 // 关注消息
@@ -38,6 +138,52 @@ type FollowMessage struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// 上舰消息
+type GuardMessage struct {
+	UserName   string    `json:"user_name"`
+	UserID     int64     `json:"user_id"`
+	GuardLevel int       `json:"guard_level"` // 1总督 2提督 3舰长
+	Num        int       `json:"num"`
+	Price      int       `json:"price"` // 单价，金瓜子
+	GiftName   string    `json:"gift_name"`
+	Timestamp  time.Time `json:"timestamp"`
+	CoinType   string    `json:"coin_type"`   // 总是gold
+	TotalPrice int64     `json:"total_price"` // 总价，金瓜子
+	CNY        float64   `json:"cny"`         // 折合人民币元
+
+	Unit     string `json:"unit"`                // 时长单位，通常为"月"
+	Renewal  bool   `json:"renewal"`             // 是否为续费
+	ToastMsg string `json:"toast_msg,omitempty"` // 全站提示文案
+}
+
+// 大航海成员
+type GuardMember struct {
+	UserName   string    `json:"user_name"`
+	UserID     int64     `json:"user_id"`
+	GuardLevel int       `json:"guard_level"`
+	ExpireAt   time.Time `json:"expire_at,omitempty"` // 按观察到的开通记录推算的到期时间，未知时为零值
+}
+
+// 醒目留言
+type SuperChatMessage struct {
+	ID        int64     `json:"id"`
+	UserName  string    `json:"user_name"`
+	UserID    int64     `json:"user_id"`
+	Message   string    `json:"message"`
+	Price     int       `json:"price"`    // 价格，人民币元
+	Duration  int       `json:"duration"` // 持续秒数
+	Timestamp time.Time `json:"timestamp"`
+
+	CoinType   string  `json:"coin_type"`   // 总是gold
+	TotalPrice int64   `json:"total_price"` // 折合金瓜子
+	CNY        float64 `json:"cny"`         // 人民币元
+
+	MessageTrans    string    `json:"message_trans,omitempty"` // 翻译后的留言
+	BackgroundColor string    `json:"background_color"`        // 背景色，如#EDF5FF
+	StartTime       time.Time `json:"start_time"`              // 开始展示的时间
+	EndTime         time.Time `json:"end_time"`                // 结束展示的时间
+}
+
 // 直播间统计
 type LiveStats struct {
 	OnlineCount int       `json:"online_count"`
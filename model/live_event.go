@@ -0,0 +1,171 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 互动类型，对应INTERACT_WORD的msg_type
+const (
+	InteractEnter         = 1 // 进入直播间
+	InteractFollow        = 2 // 关注
+	InteractShare         = 3 // 分享直播间
+	InteractSpecialFollow = 4 // 特别关注
+	InteractMutualFollow  = 5 // 互相关注
+)
+
+// 互动消息
+type InteractMessage struct {
+	MsgType   int       `json:"msg_type"` // 见Interact常量
+	UserName  string    `json:"user_name"`
+	UserID    int64     `json:"user_id"`
+	Medal     *FanMedal `json:"medal,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 分享消息
+type ShareMessage struct {
+	UserName  string    `json:"user_name"`
+	UserID    int64     `json:"user_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 点赞消息
+type LikeMessage struct {
+	UserName  string    `json:"user_name"`
+	UserID    int64     `json:"user_id"`
+	Text      string    `json:"text"` // 点赞文案，如"为主播点赞了"
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 点赞总数
+type LikeCount struct {
+	Count     int64     `json:"count"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 看过人数
+type WatchedCount struct {
+	Count     int64     `json:"count"`
+	Text      string    `json:"text"` // 显示文案，如"1.2万人看过"
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 进场特效，通常是大航海或高等级用户
+type EntryEffectMessage struct {
+	UserID     int64     `json:"user_id"`
+	Text       string    `json:"text"`        // 去掉高亮标记后的进场文案
+	GuardLevel int       `json:"guard_level"` // 大航海等级，非大航海为0
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// 开播、下播消息
+type LiveStatusMessage struct {
+	Live      bool      `json:"live"`                // true为开播，false为下播
+	LiveKey   string    `json:"live_key,omitempty"`  // 本场直播的标识，仅开播时有
+	Platform  string    `json:"platform,omitempty"`  // 开播平台，仅开播时有
+	LiveTime  time.Time `json:"live_time,omitempty"` // 开播时间，仅开播时有
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 房间信息变更
+type RoomChangeMessage struct {
+	Title          string    `json:"title"`
+	AreaName       string    `json:"area_name"`
+	ParentAreaName string    `json:"parent_area_name"`
+	Timestamp      time.Time `json:"timestamp"`
+}
+
+// 一场直播
+type LiveSession struct {
+	ID             string    `json:"id"` // 房间号与开播时间组成的场次ID
+	RoomID         int       `json:"room_id"`
+	LiveKey        string    `json:"live_key,omitempty"` // 服务器下发的场次标识，只在收到LIVE时有
+	Title          string    `json:"title"`
+	AreaName       string    `json:"area_name"`
+	ParentAreaName string    `json:"parent_area_name"`
+	Cover          string    `json:"cover"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time,omitempty"` // 直播中时为零值
+}
+
+// 禁言消息
+type BlockMessage struct {
+	UserName  string    `json:"user_name"`
+	UserID    int64     `json:"user_id"`
+	Operator  int       `json:"operator"` // 1房管 2主播
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 超管警告或切断直播
+type WarningMessage struct {
+	CutOff    bool      `json:"cut_off"` // 是否为切断直播
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 醒目留言删除
+type SuperChatDeleteMessage struct {
+	IDs       []int64   `json:"ids"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 高能榜
+type OnlineRank struct {
+	Entries   []OnlineRankEntry `json:"entries"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// 高能榜条目
+type OnlineRankEntry struct {
+	Rank       int    `json:"rank"`
+	UserName   string `json:"user_name"`
+	UserID     int64  `json:"user_id"`
+	Score      int64  `json:"score"`
+	GuardLevel int    `json:"guard_level"`
+}
+
+// 弹幕撤回
+type RecallDanmuMessage struct {
+	IDStr     string    `json:"id_str"` // 被撤回弹幕的ID，对应DanmuMessage.IDStr
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// 红包状态
+const (
+	RedPocketStart = "start" // 红包开始
+	RedPocketNew   = "new"   // 新送出的红包进入队列
+)
+
+// 人气红包
+type RedPocketMessage struct {
+	Stage      string    `json:"stage"` // 见RedPocket常量
+	LotID      int64     `json:"lot_id"`
+	SenderName string    `json:"sender_name"`
+	SenderID   int64     `json:"sender_id"`
+	Price      int       `json:"price"`                // 红包价值(电池)
+	Danmu      string    `json:"danmu,omitempty"`      // 参与口令
+	StartTime  time.Time `json:"start_time,omitempty"` // 开始时间
+	EndTime    time.Time `json:"end_time,omitempty"`   // 开奖时间
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// 人气红包中奖名单
+type RedPocketWinnerMessage struct {
+	LotID     int64             `json:"lot_id"`
+	TotalNum  int               `json:"total_num"`
+	Winners   []RedPocketWinner `json:"winners"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// 人气红包中奖者
+type RedPocketWinner struct {
+	UserName string `json:"user_name"`
+	UserID   int64  `json:"user_id"`
+}
+
+// 未识别的消息，原样透传
+type RawMessage struct {
+	Cmd  string          `json:"cmd"`
+	Data json.RawMessage `json:"data"`
+}
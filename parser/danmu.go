@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"fmt"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// ParseDanmu 解析DANMU_MSG
+//
+// info各项含义：
+// info[0] 弹幕属性：[1]模式 [2]字号 [3]颜色 [4]发送时间(毫秒) [12]弹幕类型 [13]表情包 [15]扩展信息
+// info[1] 弹幕文本
+// info[2] 用户：[0]UID [1]用户名 [2]是否房管
+// info[3] 粉丝勋章：[0]等级 [1]名称 [2]主播名 [3]房间号 [10]大航海等级 [11]是否点亮 [12]主播UID
+// info[4] 用户等级：[0]UL等级
+// info[7] 大航海等级
+// info[9] 发送时间：ts(秒)
+func ParseDanmu(msg *protocol.Message) (*model.DanmuMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	now := time.Now()
+	f.array("info")
+	danmu := &model.DanmuMessage{
+		Text:       f.str("info.1"),
+		UserID:     f.int("info.2.0"),
+		UserName:   f.str("info.2.1"),
+		Timestamp:  now,
+		Color:      fmt.Sprintf("#%06x", f.optInt("info.0.3")),
+		FontSize:   int(f.optInt("info.0.2")),
+		Mode:       int(f.optInt("info.0.1")),
+		DmType:     int(f.optInt("info.0.12")),
+		IsAdmin:    f.optBool("info.2.2"),
+		UserLevel:  int(f.optInt("info.4.0")),
+		GuardLevel: int(f.optInt("info.7")),
+		ReceivedAt: now,
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	// 优先使用毫秒精度的发送时间
+	if ms := f.optInt("info.0.4"); ms > 0 {
+		danmu.Timestamp = time.UnixMilli(ms)
+	} else if ts := f.optInt("info.9.ts"); ts > 0 {
+		danmu.Timestamp = time.Unix(ts, 0)
+	}
+
+	danmu.Medal = parseFanMedal(msg.JSON.Get("info.3"))
+	if danmu.DmType == model.DmTypeEmoticon {
+		danmu.Emoticon = parseEmoticon(msg.JSON.Get("info.0.13"))
+	}
+
+	// 扩展信息中的extra是JSON字符串，包含内嵌表情和回复对象
+	extra := msg.JSON.Get("info.0.15.extra")
+	if extra.Type == gjson.String && gjson.Valid(extra.String()) {
+		parseDanmuExtra(danmu, gjson.Parse(extra.String()))
+	}
+
+	return danmu, nil
+}
+
+// parseFanMedal 解析粉丝勋章，未佩戴时info[3]为空数组
+func parseFanMedal(medal gjson.Result) *model.FanMedal {
+	if !medal.IsArray() || medal.Get("#").Int() < 4 {
+		return nil
+	}
+
+	return &model.FanMedal{
+		Level:        int(medal.Get("0").Int()),
+		Name:         medal.Get("1").String(),
+		AnchorName:   medal.Get("2").String(),
+		AnchorRoomID: int(medal.Get("3").Int()),
+		GuardLevel:   int(medal.Get("10").Int()),
+		Lighted:      medal.Get("11").Bool(),
+		AnchorUID:    medal.Get("12").Int(),
+	}
+}
+
+// parseEmoticon 解析表情包，非表情包弹幕时为"{}"字符串
+func parseEmoticon(value gjson.Result) *model.Emoticon {
+	if !value.IsObject() {
+		return nil
+	}
+
+	url := value.Get("url").String()
+	if url == "" {
+		return nil
+	}
+
+	return &model.Emoticon{
+		Unique: value.Get("emoticon_unique").String(),
+		URL:    url,
+		Width:  int(value.Get("width").Int()),
+		Height: int(value.Get("height").Int()),
+	}
+}
+
+// parseDanmuExtra 解析扩展信息中的内嵌表情和回复对象
+func parseDanmuExtra(danmu *model.DanmuMessage, extra gjson.Result) {
+	extra.Get("emots").ForEach(func(key, value gjson.Result) bool {
+		emoticon := parseEmoticon(value)
+		if emoticon == nil {
+			return true
+		}
+
+		descript := value.Get("descript").String()
+		if descript == "" {
+			descript = key.String()
+		}
+		danmu.Emots = append(danmu.Emots, model.Emot{
+			Descript: descript,
+			Emoticon: *emoticon,
+		})
+		return true
+	})
+
+	danmu.IDStr = extra.Get("id_str").String()
+
+	if mid := extra.Get("reply_mid").Int(); mid > 0 {
+		danmu.ReplyTo = &model.ReplyTo{
+			UserID:   mid,
+			UserName: extra.Get("reply_uname").String(),
+		}
+	}
+}
+
+// ParseRecallDanmu 解析RECALL_DANMU_MSG
+func ParseRecallDanmu(msg *protocol.Message) (*model.RecallDanmuMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	recall := &model.RecallDanmuMessage{
+		IDStr:     f.get("data.target_id").String(),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return recall, nil
+}
@@ -0,0 +1,64 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// SchemaError 消息结构与预期不符
+type SchemaError struct {
+	Cmd    string // 消息命令
+	Field  string // 出错字段的gjson路径
+	Reason string // 出错原因
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s 字段 %s %s", e.Cmd, e.Field, e.Reason)
+}
+
+const (
+	reasonMissing   = "缺失"
+	reasonWrongType = "类型错误"
+	reasonDecode    = "解码失败"
+)
+
+var (
+	failureMutex sync.Mutex
+	failures     = make(map[string]uint64)
+)
+
+// recordFailure 记录一次解析失败
+func recordFailure(cmd string) {
+	failureMutex.Lock()
+	defer failureMutex.Unlock()
+
+	failures[cmd]++
+}
+
+// FailureCounts 获取各命令的解析失败次数
+func FailureCounts() map[string]uint64 {
+	failureMutex.Lock()
+	defer failureMutex.Unlock()
+
+	counts := make(map[string]uint64, len(failures))
+	for cmd, count := range failures {
+		counts[cmd] = count
+	}
+	return counts
+}
+
+// FailedCmds 获取出现过解析失败的命令，按失败次数从多到少排序
+func FailedCmds() []string {
+	counts := FailureCounts()
+
+	cmds := make([]string, 0, len(counts))
+	for cmd := range counts {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return counts[cmds[i]] > counts[cmds[j]]
+	})
+
+	return cmds
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// fields 按路径从消息中取值，必需字段缺失或类型不符时记录第一个错误
+type fields struct {
+	cmd  string
+	root gjson.Result
+	err  error
+}
+
+func newFields(cmd string, root gjson.Result) *fields {
+	return &fields{cmd: cmd, root: root}
+}
+
+func (f *fields) fail(path, reason string) {
+	if f.err == nil {
+		f.err = &SchemaError{Cmd: f.cmd, Field: path, Reason: reason}
+	}
+}
+
+// get 取出必需字段
+func (f *fields) get(path string) gjson.Result {
+	value := f.root.Get(path)
+	if !value.Exists() {
+		f.fail(path, reasonMissing)
+	}
+	return value
+}
+
+// str 必需的字符串字段
+func (f *fields) str(path string) string {
+	value := f.get(path)
+	if value.Exists() && value.Type != gjson.String {
+		f.fail(path, reasonWrongType)
+		return ""
+	}
+	return value.String()
+}
+
+// int 必需的数字字段，兼容以字符串形式下发的数字
+func (f *fields) int(path string) int64 {
+	value := f.get(path)
+	if value.Exists() && value.Type != gjson.Number && value.Type != gjson.String {
+		f.fail(path, reasonWrongType)
+		return 0
+	}
+	return value.Int()
+}
+
+// float 必需的数字字段
+func (f *fields) float(path string) float64 {
+	value := f.get(path)
+	if value.Exists() && value.Type != gjson.Number && value.Type != gjson.String {
+		f.fail(path, reasonWrongType)
+		return 0
+	}
+	return value.Float()
+}
+
+// array 必需的数组字段
+func (f *fields) array(path string) gjson.Result {
+	value := f.get(path)
+	if value.Exists() && !value.IsArray() {
+		f.fail(path, reasonWrongType)
+		return gjson.Result{}
+	}
+	return value
+}
+
+// pb 必需的base64编码protobuf字段
+func (f *fields) pb(path string) pbMessage {
+	value := f.str(path)
+	if f.err != nil {
+		return pbMessage{}
+	}
+
+	msg, err := decodePBBase64(value)
+	if err != nil {
+		f.fail(path, reasonDecode)
+		return pbMessage{}
+	}
+	return msg
+}
+
+// optStr 可选的字符串字段，缺失或类型不符时返回空字符串
+func (f *fields) optStr(path string) string {
+	value := f.root.Get(path)
+	if value.Type != gjson.String {
+		return ""
+	}
+	return value.String()
+}
+
+// optInt 可选的数字字段
+func (f *fields) optInt(path string) int64 {
+	value := f.root.Get(path)
+	if value.Type != gjson.Number && value.Type != gjson.String {
+		return 0
+	}
+	return value.Int()
+}
+
+// optFloat 可选的数字字段
+func (f *fields) optFloat(path string) float64 {
+	value := f.root.Get(path)
+	if value.Type != gjson.Number && value.Type != gjson.String {
+		return 0
+	}
+	return value.Float()
+}
+
+// optBool 可选的布尔字段，兼容0/1
+func (f *fields) optBool(path string) bool {
+	return f.root.Get(path).Bool()
+}
+
+// done 返回第一个错误并计数
+func (f *fields) done() error {
+	if f.err != nil {
+		recordFailure(f.cmd)
+	}
+	return f.err
+}
+
+// optTime 可选的秒级时间戳字段，缺失或为0时返回零值
+func (f *fields) optTime(path string) time.Time {
+	if ts := f.optInt(path); ts > 0 {
+		return time.Unix(ts, 0)
+	}
+	return time.Time{}
+}
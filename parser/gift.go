@@ -0,0 +1,235 @@
+package parser
+
+import (
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"time"
+)
+
+// ParseGift 解析SEND_GIFT
+func ParseGift(msg *protocol.Message) (*model.GiftMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	gift := &model.GiftMessage{
+		GiftName:  f.str("data.giftName"),
+		GiftID:    int(f.int("data.giftId")),
+		UserName:  f.str("data.uname"),
+		UserID:    f.int("data.uid"),
+		Num:       int(f.int("data.num")),
+		Price:     int(f.optInt("data.price")),
+		Timestamp: time.Now(),
+
+		BatchComboID: f.optStr("data.batch_combo_id"),
+		CoinType:     f.optStr("data.coin_type"),
+		TotalPrice:   f.optInt("data.total_coin"),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	// total_coin缺失时按单价计算
+	if gift.TotalPrice == 0 {
+		gift.TotalPrice = int64(gift.Num) * int64(gift.Price)
+	}
+	gift.CNY = model.CoinToCNY(gift.CoinType, gift.TotalPrice)
+	return gift, nil
+}
+
+// ParseComboSend 解析COMBO_SEND
+func ParseComboSend(msg *protocol.Message) (*model.ComboMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	combo := &model.ComboMessage{
+		BatchComboID: f.str("data.batch_combo_id"),
+		GiftName:     f.optStr("data.gift_name"),
+		GiftID:       int(f.optInt("data.gift_id")),
+		UserName:     f.optStr("data.uname"),
+		UserID:       f.optInt("data.uid"),
+		TotalNum:     int(f.int("data.total_num")),
+		TotalCoin:    f.optInt("data.combo_total_coin"),
+		Timestamp:    time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return combo, nil
+}
+
+// ParseGuard 解析GUARD_BUY
+func ParseGuard(msg *protocol.Message) (*model.GuardMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	guard := &model.GuardMessage{
+		UserName:   f.str("data.username"),
+		UserID:     f.int("data.uid"),
+		GuardLevel: int(f.int("data.guard_level")),
+		Num:        int(f.optInt("data.num")),
+		Price:      int(f.optInt("data.price")),
+		GiftName:   f.optStr("data.gift_name"),
+		Timestamp:  time.Now(),
+		CoinType:   model.CoinGold,
+		Unit:       "月",
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	guard.TotalPrice = int64(guard.Num) * int64(guard.Price)
+	guard.CNY = model.CoinToCNY(guard.CoinType, guard.TotalPrice)
+	return guard, nil
+}
+
+// 大航海开通类型，对应USER_TOAST_MSG的op_type
+const (
+	guardOpNew       = 1 // 开通
+	guardOpRenew     = 2 // 手动续费
+	guardOpAutoRenew = 3 // 自动续费
+)
+
+// ParseUserToast 解析USER_TOAST_MSG
+func ParseUserToast(msg *protocol.Message) (*model.GuardMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	guard := &model.GuardMessage{
+		UserName:   f.str("data.username"),
+		UserID:     f.int("data.uid"),
+		GuardLevel: int(f.int("data.guard_level")),
+		Num:        int(f.optInt("data.num")),
+		Price:      int(f.optInt("data.price")),
+		GiftName:   f.optStr("data.role_name"),
+		Timestamp:  time.Now(),
+		CoinType:   model.CoinGold,
+		Unit:       f.optStr("data.unit"),
+		ToastMsg:   stripHighlight(f.optStr("data.toast_msg")),
+	}
+
+	opType := f.optInt("data.op_type")
+	guard.Renewal = opType == guardOpRenew || opType == guardOpAutoRenew
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	if ts := f.optTime("data.start_time"); !ts.IsZero() {
+		guard.Timestamp = ts
+	}
+	guard.TotalPrice = int64(guard.Num) * int64(guard.Price)
+	guard.CNY = model.CoinToCNY(guard.CoinType, guard.TotalPrice)
+	return guard, nil
+}
+
+// ParseSuperChat 解析SUPER_CHAT_MESSAGE和SUPER_CHAT_MESSAGE_JPN
+func ParseSuperChat(msg *protocol.Message) (*model.SuperChatMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	superChat := &model.SuperChatMessage{
+		ID:        f.int("data.id"),
+		UserName:  f.str("data.user_info.uname"),
+		UserID:    f.int("data.uid"),
+		Message:   f.str("data.message"),
+		Price:     int(f.int("data.price")),
+		Duration:  int(f.optInt("data.time")),
+		Timestamp: time.Now(),
+		CoinType:  model.CoinGold,
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	// 醒目留言以元计价
+	superChat.TotalPrice = int64(superChat.Price) * model.GoldPerCNY
+	superChat.CNY = float64(superChat.Price)
+
+	superChat.MessageTrans = f.optStr("data.message_trans")
+	if superChat.MessageTrans == "" {
+		superChat.MessageTrans = f.optStr("data.message_jpn")
+	}
+	superChat.BackgroundColor = f.optStr("data.background_color")
+
+	// 展示时间以服务器下发的为准，缺失时按收到时间和持续秒数推算
+	superChat.StartTime = f.optTime("data.start_time")
+	if superChat.StartTime.IsZero() {
+		superChat.StartTime = superChat.Timestamp
+	}
+	superChat.EndTime = f.optTime("data.end_time")
+	if superChat.EndTime.IsZero() {
+		superChat.EndTime = superChat.StartTime.Add(time.Duration(superChat.Duration) * time.Second)
+	}
+	return superChat, nil
+}
+
+// ParseSuperChatDelete 解析SUPER_CHAT_MESSAGE_DELETE
+func ParseSuperChatDelete(msg *protocol.Message) (*model.SuperChatDeleteMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+	ids := f.array("data.ids")
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	deleted := &model.SuperChatDeleteMessage{
+		Timestamp: time.Now(),
+	}
+	for _, id := range ids.Array() {
+		deleted.IDs = append(deleted.IDs, id.Int())
+	}
+	return deleted, nil
+}
+
+// ParseRedPocket 解析POPULARITY_RED_POCKET_START和POPULARITY_RED_POCKET_NEW
+func ParseRedPocket(msg *protocol.Message) (*model.RedPocketMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	pocket := &model.RedPocketMessage{
+		LotID:     f.int("data.lot_id"),
+		StartTime: f.optTime("data.start_time"),
+		EndTime:   f.optTime("data.end_time"),
+		Timestamp: time.Now(),
+	}
+
+	// 两种消息的发送者字段名不同
+	if msg.Cmd == protocol.CmdRedPocketStart {
+		pocket.Stage = model.RedPocketStart
+		pocket.SenderName = f.str("data.sender_name")
+		pocket.SenderID = f.int("data.sender_uid")
+		pocket.Price = int(f.optInt("data.total_price"))
+		pocket.Danmu = f.optStr("data.danmu")
+	} else {
+		pocket.Stage = model.RedPocketNew
+		pocket.SenderName = f.str("data.uname")
+		pocket.SenderID = f.int("data.uid")
+		pocket.Price = int(f.optInt("data.price"))
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return pocket, nil
+}
+
+// ParseRedPocketWinners 解析POPULARITY_RED_POCKET_WINNER_LIST，winner_info每项为[uid, uname, ...]
+func ParseRedPocketWinners(msg *protocol.Message) (*model.RedPocketWinnerMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	winners := &model.RedPocketWinnerMessage{
+		LotID:     f.int("data.lot_id"),
+		TotalNum:  int(f.optInt("data.total_num")),
+		Timestamp: time.Now(),
+	}
+	list := f.array("data.winner_info")
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	for _, item := range list.Array() {
+		winners.Winners = append(winners.Winners, model.RedPocketWinner{
+			UserID:   item.Get("0").Int(),
+			UserName: item.Get("1").String(),
+		})
+	}
+	return winners, nil
+}
@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// ParseInteract 解析INTERACT_WORD和INTERACT_WORD_V2
+func ParseInteract(msg *protocol.Message) (*model.InteractMessage, error) {
+	if msg.Cmd == protocol.CmdInteractWordV2 {
+		return parseInteractPB(msg)
+	}
+
+	f := newFields(msg.Cmd, msg.JSON)
+
+	interact := &model.InteractMessage{
+		MsgType:   int(f.int("data.msg_type")),
+		UserName:  f.str("data.uname"),
+		UserID:    f.int("data.uid"),
+		Medal:     parseMedalObject(msg.JSON.Get("data.fans_medal")),
+		Timestamp: f.optTime("data.timestamp"),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	if interact.Timestamp.IsZero() {
+		interact.Timestamp = time.Now()
+	}
+	return interact, nil
+}
+
+// parseInteractPB 解析INTERACT_WORD_V2中的protobuf
+//
+// InteractWord字段号：1 uid，2 uname，5 msg_type，6 roomid，7 timestamp，9 fans_medal
+func parseInteractPB(msg *protocol.Message) (*model.InteractMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	pb := f.pb("data.pb")
+	if f.err == nil {
+		switch {
+		case !pb.has(2) || !pb.has(5):
+			f.fail("data.pb", reasonMissing)
+		case !pb.typed(1, pbVarint) || !pb.typed(2, pbBytes) || !pb.typed(5, pbVarint) ||
+			!pb.typed(7, pbVarint) || !pb.typed(9, pbBytes):
+			f.fail("data.pb", reasonWrongType)
+		}
+	}
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	interact := &model.InteractMessage{
+		MsgType:   int(pb.int(5)),
+		UserName:  pb.str(2),
+		UserID:    pb.int(1),
+		Medal:     parseMedalPB(pb.message(9)),
+		Timestamp: time.Now(),
+	}
+	if ts := pb.int(7); ts > 0 {
+		interact.Timestamp = time.Unix(ts, 0)
+	}
+	return interact, nil
+}
+
+// ParseLikeClick 解析LIKE_INFO_V3_CLICK
+func ParseLikeClick(msg *protocol.Message) (*model.LikeMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	like := &model.LikeMessage{
+		UserName:  f.str("data.uname"),
+		UserID:    f.int("data.uid"),
+		Text:      f.optStr("data.like_text"),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return like, nil
+}
+
+// ParseLikeUpdate 解析LIKE_INFO_V3_UPDATE
+func ParseLikeUpdate(msg *protocol.Message) (*model.LikeCount, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	count := &model.LikeCount{
+		Count:     f.int("data.click_count"),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+// ParseWatchedChange 解析WATCHED_CHANGE
+func ParseWatchedChange(msg *protocol.Message) (*model.WatchedCount, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	watched := &model.WatchedCount{
+		Count:     f.int("data.num"),
+		Text:      f.optStr("data.text_large"),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return watched, nil
+}
+
+// ParseEntryEffect 解析ENTRY_EFFECT
+func ParseEntryEffect(msg *protocol.Message) (*model.EntryEffectMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	effect := &model.EntryEffectMessage{
+		UserID:     f.int("data.uid"),
+		Text:       stripHighlight(f.str("data.copy_writing")),
+		GuardLevel: int(f.optInt("data.privilege_type")),
+		Timestamp:  time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return effect, nil
+}
+
+// stripHighlight 去掉文案中<% %>形式的高亮标记
+func stripHighlight(text string) string {
+	return strings.NewReplacer("<%", "", "%>", "").Replace(text)
+}
+
+// parseMedalObject 解析对象形式的粉丝勋章，等级为0表示未佩戴
+func parseMedalObject(medal gjson.Result) *model.FanMedal {
+	if !medal.IsObject() || medal.Get("medal_level").Int() == 0 {
+		return nil
+	}
+
+	return &model.FanMedal{
+		Name:         medal.Get("medal_name").String(),
+		Level:        int(medal.Get("medal_level").Int()),
+		AnchorRoomID: int(medal.Get("anchor_roomid").Int()),
+		AnchorUID:    medal.Get("target_id").Int(),
+		GuardLevel:   int(medal.Get("guard_level").Int()),
+		Lighted:      medal.Get("is_lighted").Bool(),
+	}
+}
+
+// parseMedalPB 解析protobuf形式的粉丝勋章
+//
+// FansMedalInfo字段号：1 target_id，2 medal_level，3 medal_name，8 is_lighted，9 guard_level，12 anchor_roomid
+func parseMedalPB(medal pbMessage) *model.FanMedal {
+	if medal.int(2) == 0 {
+		return nil
+	}
+
+	return &model.FanMedal{
+		Name:         medal.str(3),
+		Level:        int(medal.int(2)),
+		AnchorRoomID: int(medal.int(12)),
+		AnchorUID:    medal.int(1),
+		GuardLevel:   int(medal.int(9)),
+		Lighted:      medal.bool(8),
+	}
+}
@@ -0,0 +1,440 @@
+package parser
+
+import (
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"encoding/base64"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// loadFixture 读取testdata中的消息
+func loadFixture(t *testing.T, name string) *protocol.Message {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := protocol.ParseMessage(data)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return msg
+}
+
+func TestParseDanmu(t *testing.T) {
+	tests := []struct {
+		file  string
+		check func(t *testing.T, danmu *model.DanmuMessage)
+	}{
+		{"danmu_msg.json", func(t *testing.T, danmu *model.DanmuMessage) {
+			if danmu.Text != "主播晚上好[dog]" || danmu.UserID != 12345678 || danmu.UserName != "测试观众" {
+				t.Errorf("基本字段 = %q %d %q", danmu.Text, danmu.UserID, danmu.UserName)
+			}
+			if !danmu.Timestamp.Equal(time.UnixMilli(1700000000123)) {
+				t.Errorf("Timestamp = %v", danmu.Timestamp)
+			}
+			if !danmu.IsAdmin || danmu.UserLevel != 16 || danmu.GuardLevel != model.GuardLevelCaptain {
+				t.Errorf("IsAdmin = %v, UserLevel = %d, GuardLevel = %d", danmu.IsAdmin, danmu.UserLevel, danmu.GuardLevel)
+			}
+			if danmu.Medal == nil || danmu.Medal.Name != "天河" || danmu.Medal.Level != 21 || danmu.Medal.AnchorUID != 4370836 || !danmu.Medal.Lighted {
+				t.Errorf("Medal = %+v", danmu.Medal)
+			}
+			if len(danmu.Emots) != 1 || danmu.Emots[0].Descript != "[dog]" || danmu.Emots[0].Width != 20 {
+				t.Errorf("Emots = %+v", danmu.Emots)
+			}
+			if danmu.IDStr != "a1b2c3d4e5f60718" || danmu.ReplyTo != nil {
+				t.Errorf("IDStr = %q, ReplyTo = %+v", danmu.IDStr, danmu.ReplyTo)
+			}
+		}},
+		{"danmu_msg_emoticon.json", func(t *testing.T, danmu *model.DanmuMessage) {
+			if !danmu.IsEmoticonOnly() || danmu.Emoticon == nil || danmu.Emoticon.Unique != "room_21452505_1234" || danmu.Emoticon.Height != 162 {
+				t.Errorf("Emoticon = %+v", danmu.Emoticon)
+			}
+			if danmu.Medal != nil {
+				t.Errorf("未佩戴勋章时Medal应为nil: %+v", danmu.Medal)
+			}
+			if danmu.ReplyTo == nil || danmu.ReplyTo.UserID != 87654321 || danmu.ReplyTo.UserName != "被回复的人" {
+				t.Errorf("ReplyTo = %+v", danmu.ReplyTo)
+			}
+		}},
+		{"danmu_msg_legacy.json", func(t *testing.T, danmu *model.DanmuMessage) {
+			if danmu.Text != "老版本弹幕" || danmu.UserID != 34567890 {
+				t.Errorf("基本字段 = %q %d", danmu.Text, danmu.UserID)
+			}
+			// 毫秒时间为0时使用info[9].ts
+			if !danmu.Timestamp.Equal(time.Unix(1600000000, 0)) {
+				t.Errorf("Timestamp = %v", danmu.Timestamp)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			danmu, err := ParseDanmu(loadFixture(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, danmu)
+		})
+	}
+}
+
+func TestParseGift(t *testing.T) {
+	tests := []struct {
+		file  string
+		check func(t *testing.T, gift *model.GiftMessage)
+	}{
+		{"send_gift.json", func(t *testing.T, gift *model.GiftMessage) {
+			if gift.GiftName != "小花花" || gift.GiftID != 31036 || gift.Num != 5 || gift.UserID != 12345678 {
+				t.Errorf("基本字段 = %+v", gift)
+			}
+			if gift.CoinType != model.CoinGold || gift.TotalPrice != 500 || gift.CNY != 0.5 {
+				t.Errorf("CoinType = %s, TotalPrice = %d, CNY = %v", gift.CoinType, gift.TotalPrice, gift.CNY)
+			}
+			if gift.BatchComboID == "" {
+				t.Error("BatchComboID为空")
+			}
+		}},
+		{"send_gift_silver.json", func(t *testing.T, gift *model.GiftMessage) {
+			// 字符串形式的uid，缺少total_coin时按单价计算，银瓜子不折算人民币
+			if gift.UserID != 45678901 || gift.TotalPrice != 1000 || gift.CNY != 0 {
+				t.Errorf("UserID = %d, TotalPrice = %d, CNY = %v", gift.UserID, gift.TotalPrice, gift.CNY)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			gift, err := ParseGift(loadFixture(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, gift)
+		})
+	}
+}
+
+// 结构变化的消息应返回SchemaError并计数，不能panic
+func TestParseDrifted(t *testing.T) {
+	tests := []struct {
+		file   string
+		parse  func(msg *protocol.Message) error
+		field  string
+		reason string
+	}{
+		{"danmu_msg_drift_info_object.json", parseDanmuErr, "info", reasonWrongType},
+		{"danmu_msg_drift_text_number.json", parseDanmuErr, "info.1", reasonWrongType},
+		{"danmu_msg_drift_no_user.json", parseDanmuErr, "info.2.0", reasonMissing},
+		{"send_gift_drift_renamed.json", parseGiftErr, "data.giftName", reasonMissing},
+		{"send_gift_drift_uid_object.json", parseGiftErr, "data.uid", reasonWrongType},
+		{"send_gift_drift_no_data.json", parseGiftErr, "data.giftName", reasonMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			msg := loadFixture(t, tt.file)
+			before := FailureCounts()[msg.Cmd]
+
+			err := tt.parse(msg)
+
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("err = %v, 应为*SchemaError", err)
+			}
+			if schemaErr.Cmd != msg.Cmd || schemaErr.Field != tt.field || schemaErr.Reason != tt.reason {
+				t.Errorf("SchemaError = %+v, want field %s reason %s", schemaErr, tt.field, tt.reason)
+			}
+			if after := FailureCounts()[msg.Cmd]; after != before+1 {
+				t.Errorf("%s 失败次数 %d -> %d", msg.Cmd, before, after)
+			}
+		})
+	}
+}
+
+func parseDanmuErr(msg *protocol.Message) error {
+	_, err := ParseDanmu(msg)
+	return err
+}
+
+func parseGiftErr(msg *protocol.Message) error {
+	_, err := ParseGift(msg)
+	return err
+}
+
+func TestParseInteractPB(t *testing.T) {
+	interact, err := ParseInteract(loadFixture(t, "interact_word_v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interact.UserID != 12345678 || interact.UserName != "测试观众" || interact.MsgType != 2 {
+		t.Errorf("基本字段 = %d %q %d", interact.UserID, interact.UserName, interact.MsgType)
+	}
+	if !interact.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Timestamp = %v", interact.Timestamp)
+	}
+	medal := interact.Medal
+	if medal == nil || medal.Name != "天河" || medal.Level != 21 || medal.AnchorUID != 4370836 ||
+		medal.AnchorRoomID != 21452505 || medal.GuardLevel != 3 || !medal.Lighted {
+		t.Errorf("Medal = %+v", medal)
+	}
+
+	enter, err := ParseInteract(loadFixture(t, "interact_word_v2_enter.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enter.UserID != 23456789 || enter.UserName != "路过的观众" || enter.MsgType != 1 || enter.Medal != nil {
+		t.Errorf("进房 = %+v", enter)
+	}
+	if enter.Timestamp.IsZero() {
+		t.Error("缺少时间戳时应使用当前时间")
+	}
+}
+
+func TestParseOnlineRankPB(t *testing.T) {
+	rank, err := ParseOnlineRank(loadFixture(t, "online_rank_v3.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []model.OnlineRankEntry{
+		{Rank: 1, UserName: "测试观众", UserID: 12345678, Score: 5200, GuardLevel: 3},
+		{Rank: 2, UserName: "第二名", UserID: 23456789, Score: 1000},
+		{Rank: 3, UserName: "第三名", UserID: 34567890, Score: 10},
+	}
+	if len(rank.Entries) != len(want) {
+		t.Fatalf("榜单 = %+v", rank.Entries)
+	}
+	for i := range want {
+		if rank.Entries[i] != want[i] {
+			t.Errorf("第%d名 = %+v, want %+v", i+1, rank.Entries[i], want[i])
+		}
+	}
+}
+
+// 截断或线格式类型不符的protobuf应返回SchemaError
+func TestParsePBDrifted(t *testing.T) {
+	tests := []struct {
+		file   string
+		parse  func(msg *protocol.Message) error
+		reason string
+	}{
+		{"interact_word_v2_drift_truncated.json", parseInteractErr, reasonDecode},
+		{"interact_word_v2_drift_wiretype.json", parseInteractErr, reasonWrongType},
+		{"interact_word_v2_drift_base64.json", parseInteractErr, reasonDecode},
+		{"online_rank_v3_drift_truncated.json", parseOnlineRankErr, reasonDecode},
+		{"online_rank_v3_drift_wiretype.json", parseOnlineRankErr, reasonWrongType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			msg := loadFixture(t, tt.file)
+			before := FailureCounts()[msg.Cmd]
+
+			err := tt.parse(msg)
+
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("err = %v, 应为*SchemaError", err)
+			}
+			if schemaErr.Field != "data.pb" || schemaErr.Reason != tt.reason {
+				t.Errorf("SchemaError = %+v, want reason %s", schemaErr, tt.reason)
+			}
+			if after := FailureCounts()[msg.Cmd]; after != before+1 {
+				t.Errorf("%s 失败次数 %d -> %d", msg.Cmd, before, after)
+			}
+		})
+	}
+}
+
+// 任意截断位置都只能返回错误，不能panic
+func TestDecodePBTruncated(t *testing.T) {
+	msg := loadFixture(t, "interact_word_v2.json")
+	data, err := base64.StdEncoding.DecodeString(msg.JSON.Get("data.pb").String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 恰好在字段边界截断时可以解码，否则返回errPBTruncated
+	for n := range data {
+		if _, err := decodePB(data[:n]); err != nil && err != errPBTruncated {
+			t.Errorf("截断到%d字节 err = %v", n, err)
+		}
+	}
+
+	if _, err := decodePB([]byte{0x08, 0x80}); err != errPBTruncated {
+		t.Errorf("不完整的varint err = %v", err)
+	}
+	if _, err := decodePB([]byte{0x12, 0x05, 'a'}); err != errPBTruncated {
+		t.Errorf("长度超出数据 err = %v", err)
+	}
+	if _, err := decodePB([]byte{0x0b}); err == nil {
+		t.Error("不支持的线格式类型应返回错误")
+	}
+	if _, err := decodePB([]byte{0x00, 0x01}); err == nil {
+		t.Error("字段号0应返回错误")
+	}
+}
+
+func parseInteractErr(msg *protocol.Message) error {
+	_, err := ParseInteract(msg)
+	return err
+}
+
+func parseOnlineRankErr(msg *protocol.Message) error {
+	_, err := ParseOnlineRank(msg)
+	return err
+}
+
+// 新版命令目录中各命令的解析
+func TestParseCatalog(t *testing.T) {
+	tests := []struct {
+		file  string
+		check func(t *testing.T, msg *protocol.Message)
+	}{
+		{"interact_word.json", func(t *testing.T, msg *protocol.Message) {
+			interact, err := ParseInteract(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if interact.MsgType != model.InteractFollow || interact.UserID != 12345678 || interact.Medal == nil || interact.Medal.Level != 12 {
+				t.Errorf("%+v", interact)
+			}
+		}},
+		{"like_info_v3_click.json", func(t *testing.T, msg *protocol.Message) {
+			like, err := ParseLikeClick(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if like.UserID != 12345678 || like.UserName != "测试观众" || like.Text != "为主播点赞了" {
+				t.Errorf("%+v", like)
+			}
+		}},
+		{"like_info_v3_update.json", func(t *testing.T, msg *protocol.Message) {
+			count, err := ParseLikeUpdate(msg)
+			if err != nil || count.Count != 12345 {
+				t.Errorf("%+v, %v", count, err)
+			}
+		}},
+		{"watched_change.json", func(t *testing.T, msg *protocol.Message) {
+			watched, err := ParseWatchedChange(msg)
+			if err != nil || watched.Count != 12000 || watched.Text != "1.2万人看过" {
+				t.Errorf("%+v, %v", watched, err)
+			}
+		}},
+		{"entry_effect.json", func(t *testing.T, msg *protocol.Message) {
+			effect, err := ParseEntryEffect(msg)
+			if err != nil || effect.UserID != 12345678 || effect.GuardLevel != 3 || effect.Text != "欢迎舰长 测试观众 进入直播间" {
+				t.Errorf("%+v, %v", effect, err)
+			}
+		}},
+		{"live.json", func(t *testing.T, msg *protocol.Message) {
+			status, err := ParseLiveStatus(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !status.Live || status.LiveKey != "460695439410455558" || status.Platform != "pc_link" || !status.LiveTime.Equal(time.Unix(1700000000, 0)) {
+				t.Errorf("%+v", status)
+			}
+		}},
+		{"preparing.json", func(t *testing.T, msg *protocol.Message) {
+			status, err := ParseLiveStatus(msg)
+			if err != nil || status.Live || status.LiveKey != "" {
+				t.Errorf("%+v, %v", status, err)
+			}
+		}},
+		{"room_block_msg.json", func(t *testing.T, msg *protocol.Message) {
+			block, err := ParseRoomBlock(msg)
+			if err != nil || block.UserID != 87654321 || block.UserName != "被禁言的人" || block.Operator != 1 {
+				t.Errorf("%+v, %v", block, err)
+			}
+		}},
+		{"cut_off.json", func(t *testing.T, msg *protocol.Message) {
+			warning, err := ParseWarning(msg)
+			if err != nil || !warning.CutOff || warning.Message != "违反直播规范" {
+				t.Errorf("%+v, %v", warning, err)
+			}
+		}},
+		{"super_chat_message_delete.json", func(t *testing.T, msg *protocol.Message) {
+			deleted, err := ParseSuperChatDelete(msg)
+			if err != nil || len(deleted.IDs) != 2 || deleted.IDs[0] != 8765432 || deleted.IDs[1] != 8765433 {
+				t.Errorf("%+v, %v", deleted, err)
+			}
+		}},
+		{"recall_danmu_msg.json", func(t *testing.T, msg *protocol.Message) {
+			recall, err := ParseRecallDanmu(msg)
+			if err != nil || recall.IDStr != "a1b2c3d4e5f60718" {
+				t.Errorf("%+v, %v", recall, err)
+			}
+		}},
+		{"popularity_red_pocket_start.json", func(t *testing.T, msg *protocol.Message) {
+			pocket, err := ParseRedPocket(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pocket.Stage != model.RedPocketStart || pocket.LotID != 13579 || pocket.SenderID != 12345678 ||
+				pocket.Price != 1600 || pocket.Danmu == "" || !pocket.EndTime.Equal(time.Unix(1700000180, 0)) {
+				t.Errorf("%+v", pocket)
+			}
+		}},
+		{"popularity_red_pocket_new.json", func(t *testing.T, msg *protocol.Message) {
+			pocket, err := ParseRedPocket(msg)
+			if err != nil || pocket.Stage != model.RedPocketNew || pocket.SenderID != 23456789 || pocket.SenderName != "第二个红包" || pocket.Price != 20 {
+				t.Errorf("%+v, %v", pocket, err)
+			}
+		}},
+		{"popularity_red_pocket_winner_list.json", func(t *testing.T, msg *protocol.Message) {
+			winners, err := ParseRedPocketWinners(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if winners.LotID != 13579 || winners.TotalNum != 2 || len(winners.Winners) != 2 ||
+				winners.Winners[1].UserID != 34567890 || winners.Winners[1].UserName != "中奖者二" {
+				t.Errorf("%+v", winners)
+			}
+		}},
+		{"online_rank_v2.json", func(t *testing.T, msg *protocol.Message) {
+			rank, err := ParseOnlineRank(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := model.OnlineRankEntry{Rank: 1, UserName: "测试观众", UserID: 12345678, Score: 5200, GuardLevel: 3}
+			if len(rank.Entries) != 2 || rank.Entries[0] != want {
+				t.Errorf("%+v", rank.Entries)
+			}
+		}},
+		{"danmu_msg_suffix.json", func(t *testing.T, msg *protocol.Message) {
+			// 带参数后缀的旧版命令按去掉后缀的命令解析
+			if msg.Cmd != protocol.CmdDanmu {
+				t.Fatalf("Cmd = %q", msg.Cmd)
+			}
+			if danmu, err := ParseDanmu(msg); err != nil || danmu.Text != "主播晚上好[dog]" {
+				t.Errorf("%+v, %v", danmu, err)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			msg := loadFixture(t, tt.file)
+			if !protocol.IsValidMessage(msg.Cmd) {
+				t.Errorf("%s 不是已知命令", msg.Cmd)
+			}
+			tt.check(t, msg)
+		})
+	}
+}
+
+// NOTICE_MSG是全站广播，没有内置解析，作为未知命令以原始消息透传
+func TestNoticeIsUnknown(t *testing.T) {
+	msg := loadFixture(t, "notice_msg.json")
+	if protocol.IsValidMessage(msg.Cmd) {
+		t.Errorf("%s 不应是已知命令", msg.Cmd)
+	}
+	if priority := protocol.GetMessagePriority(msg.Cmd); priority != protocol.PriorityLowest {
+		t.Errorf("优先级 = %d, want %d", priority, protocol.PriorityLowest)
+	}
+}
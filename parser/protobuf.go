@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"encoding/base64"
+	"errors"
+)
+
+// protobuf线格式类型
+const (
+	pbVarint  = 0
+	pbFixed64 = 1
+	pbBytes   = 2
+	pbFixed32 = 5
+)
+
+var errPBTruncated = errors.New("protobuf数据不完整")
+
+// pbValue 一个字段值，varint和定长类型存放在num中，长度分隔类型存放在bytes中
+type pbValue struct {
+	wireType int
+	num      uint64
+	bytes    []byte
+}
+
+// pbMessage 按字段号分组的protobuf消息，只做线格式解码，字段含义由调用方决定
+type pbMessage map[int][]pbValue
+
+// decodePBBase64 解码data.pb中base64编码的protobuf
+func decodePBBase64(s string) (pbMessage, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return decodePB(data)
+}
+
+// decodePB 解码protobuf线格式
+func decodePB(data []byte) (pbMessage, error) {
+	msg := make(pbMessage)
+
+	for len(data) > 0 {
+		key, n := readVarint(data)
+		if n == 0 {
+			return nil, errPBTruncated
+		}
+		data = data[n:]
+
+		field := int(key >> 3)
+		value := pbValue{wireType: int(key & 7)}
+		if field <= 0 {
+			return nil, errors.New("protobuf字段号无效")
+		}
+
+		switch value.wireType {
+		case pbVarint:
+			value.num, n = readVarint(data)
+			if n == 0 {
+				return nil, errPBTruncated
+			}
+			data = data[n:]
+		case pbFixed64:
+			if len(data) < 8 {
+				return nil, errPBTruncated
+			}
+			for i := 7; i >= 0; i-- {
+				value.num = value.num<<8 | uint64(data[i])
+			}
+			data = data[8:]
+		case pbFixed32:
+			if len(data) < 4 {
+				return nil, errPBTruncated
+			}
+			for i := 3; i >= 0; i-- {
+				value.num = value.num<<8 | uint64(data[i])
+			}
+			data = data[4:]
+		case pbBytes:
+			length, n := readVarint(data)
+			if n == 0 || length > uint64(len(data)-n) {
+				return nil, errPBTruncated
+			}
+			data = data[n:]
+			value.bytes = data[:length]
+			data = data[length:]
+		default:
+			return nil, errors.New("不支持的protobuf线格式类型")
+		}
+
+		msg[field] = append(msg[field], value)
+	}
+
+	return msg, nil
+}
+
+// readVarint 读取一个varint，返回值和占用的字节数，数据不完整时字节数为0
+func readVarint(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 10; i++ {
+		value |= uint64(data[i]&0x7f) << (7 * i)
+		if data[i] < 0x80 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
+
+// last 取字段的最后一个值，与protobuf对重复标量字段的处理一致
+func (m pbMessage) last(field int) (pbValue, bool) {
+	values := m[field]
+	if len(values) == 0 {
+		return pbValue{}, false
+	}
+	return values[len(values)-1], true
+}
+
+// has 字段是否存在
+func (m pbMessage) has(field int) bool {
+	return len(m[field]) > 0
+}
+
+// typed 字段的所有值是否都是给定的线格式类型，字段不存在时也为true
+func (m pbMessage) typed(field, wireType int) bool {
+	for _, value := range m[field] {
+		if value.wireType != wireType {
+			return false
+		}
+	}
+	return true
+}
+
+// int int64字段
+func (m pbMessage) int(field int) int64 {
+	value, ok := m.last(field)
+	if !ok || value.wireType == pbBytes {
+		return 0
+	}
+	return int64(value.num)
+}
+
+// bool bool字段
+func (m pbMessage) bool(field int) bool {
+	return m.int(field) != 0
+}
+
+// str string字段
+func (m pbMessage) str(field int) string {
+	value, ok := m.last(field)
+	if !ok || value.wireType != pbBytes {
+		return ""
+	}
+	return string(value.bytes)
+}
+
+// message 嵌套消息字段，不存在或解码失败时返回空消息
+func (m pbMessage) message(field int) pbMessage {
+	value, ok := m.last(field)
+	if !ok || value.wireType != pbBytes {
+		return pbMessage{}
+	}
+
+	sub, err := decodePB(value.bytes)
+	if err != nil {
+		return pbMessage{}
+	}
+	return sub
+}
+
+// messages 重复的嵌套消息字段，跳过解码失败的项
+func (m pbMessage) messages(field int) []pbMessage {
+	var list []pbMessage
+	for _, value := range m[field] {
+		if value.wireType != pbBytes {
+			continue
+		}
+		if sub, err := decodePB(value.bytes); err == nil {
+			list = append(list, sub)
+		}
+	}
+	return list
+}
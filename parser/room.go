@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"TianHe-API/model"
+	"TianHe-API/protocol"
+	"strconv"
+	"time"
+)
+
+// ParseWelcome 解析旧版WELCOME
+func ParseWelcome(msg *protocol.Message) (*model.WelcomeMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	welcome := &model.WelcomeMessage{
+		UserName:  f.str("data.uname"),
+		UserID:    f.int("data.uid"),
+		Timestamp: time.Now(),
+		IsVip:     f.optInt("data.vip") > 0 || f.optInt("data.svip") > 0,
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return welcome, nil
+}
+
+// ParseWelcomeGuard 解析旧版WELCOME_GUARD
+func ParseWelcomeGuard(msg *protocol.Message) (*model.WelcomeMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	welcome := &model.WelcomeMessage{
+		UserName:   f.str("data.username"),
+		UserID:     f.int("data.uid"),
+		Timestamp:  time.Now(),
+		GuardLevel: int(f.optInt("data.guard_level")),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return welcome, nil
+}
+
+// ParseOnlineCount 解析ONLINE_RANK_COUNT
+func ParseOnlineCount(msg *protocol.Message) (*model.LiveStats, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	stats := &model.LiveStats{
+		OnlineCount: int(f.int("data.count")),
+		Timestamp:   time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ParseLiveStatus 解析LIVE和PREPARING
+func ParseLiveStatus(msg *protocol.Message) (*model.LiveStatusMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	status := &model.LiveStatusMessage{
+		Live:      msg.Cmd == protocol.CmdLive,
+		LiveKey:   f.optStr("live_key"),
+		Platform:  f.optStr("live_platform"),
+		LiveTime:  f.optTime("live_time"),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// ParseRoomChange 解析ROOM_CHANGE
+func ParseRoomChange(msg *protocol.Message) (*model.RoomChangeMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	change := &model.RoomChangeMessage{
+		Title:          f.str("data.title"),
+		AreaName:       f.optStr("data.area_name"),
+		ParentAreaName: f.optStr("data.parent_area_name"),
+		Timestamp:      time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// ParseRoomBlock 解析ROOM_BLOCK_MSG
+func ParseRoomBlock(msg *protocol.Message) (*model.BlockMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	block := &model.BlockMessage{
+		UserName:  f.str("data.uname"),
+		UserID:    f.int("data.uid"),
+		Operator:  int(f.optInt("data.operator")),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// ParseWarning 解析WARNING和CUT_OFF
+func ParseWarning(msg *protocol.Message) (*model.WarningMessage, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+
+	warning := &model.WarningMessage{
+		CutOff:    msg.Cmd == protocol.CmdCutOff,
+		Message:   f.str("msg"),
+		Timestamp: time.Now(),
+	}
+
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+	return warning, nil
+}
+
+// ParseOnlineRank 解析ONLINE_RANK_V2和ONLINE_RANK_V3，新版榜单在online_list中
+func ParseOnlineRank(msg *protocol.Message) (*model.OnlineRank, error) {
+	if msg.Cmd == protocol.CmdOnlineRankV3 {
+		return parseOnlineRankPB(msg)
+	}
+
+	path := "data.list"
+	if msg.JSON.Get("data.online_list").Exists() {
+		path = "data.online_list"
+	}
+
+	f := newFields(msg.Cmd, msg.JSON)
+	list := f.array(path)
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	rank := &model.OnlineRank{
+		Entries:   make([]model.OnlineRankEntry, 0, len(list.Array())),
+		Timestamp: time.Now(),
+	}
+	for _, item := range list.Array() {
+		rank.Entries = append(rank.Entries, model.OnlineRankEntry{
+			Rank:       int(item.Get("rank").Int()),
+			UserName:   item.Get("uname").String(),
+			UserID:     item.Get("uid").Int(),
+			Score:      item.Get("score").Int(),
+			GuardLevel: int(item.Get("guard_level").Int()),
+		})
+	}
+	return rank, nil
+}
+
+// parseOnlineRankPB 解析ONLINE_RANK_V3中的protobuf
+//
+// GoldRankBroadcast字段号：2 list，3 online_list
+// GoldRankBroadcastItem字段号：1 uid，3 score，4 uname，5 rank，6 guard_level
+func parseOnlineRankPB(msg *protocol.Message) (*model.OnlineRank, error) {
+	f := newFields(msg.Cmd, msg.JSON)
+	pb := f.pb("data.pb")
+	if f.err == nil && (!pb.typed(2, pbBytes) || !pb.typed(3, pbBytes)) {
+		f.fail("data.pb", reasonWrongType)
+	}
+
+	items := pb.messages(3)
+	if len(items) == 0 {
+		items = pb.messages(2)
+	}
+	for _, item := range items {
+		if !item.typed(1, pbVarint) || !item.typed(3, pbBytes) || !item.typed(4, pbBytes) || !item.typed(5, pbVarint) {
+			f.fail("data.pb", reasonWrongType)
+		}
+	}
+	if err := f.done(); err != nil {
+		return nil, err
+	}
+
+	rank := &model.OnlineRank{
+		Entries:   make([]model.OnlineRankEntry, 0, len(items)),
+		Timestamp: time.Now(),
+	}
+	for _, item := range items {
+		score, _ := strconv.ParseInt(item.str(3), 10, 64)
+		rank.Entries = append(rank.Entries, model.OnlineRankEntry{
+			Rank:       int(item.int(5)),
+			UserName:   item.str(4),
+			UserID:     item.int(1),
+			Score:      score,
+			GuardLevel: int(item.int(6)),
+		})
+	}
+	return rank, nil
+}
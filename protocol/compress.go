@@ -0,0 +1,33 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// IsCompressed 检查数据包包体是否为压缩的批量数据包
+func (p *Packet) IsCompressed() bool {
+	return p.Operation == OpMessage && (p.Version == ProtoVerZlib || p.Version == ProtoVerBrotli)
+}
+
+// Decompress 按协议版本解压包体
+func Decompress(version int16, data []byte) ([]byte, error) {
+	switch version {
+	case ProtoVerZlib:
+		reader, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return io.ReadAll(reader)
+	case ProtoVerBrotli:
+		return io.ReadAll(brotli.NewReader(bytes.NewReader(data)))
+	default:
+		return nil, fmt.Errorf("不支持的压缩协议版本: %d", version)
+	}
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/tidwall/gjson"
 )
@@ -11,48 +12,102 @@ import (
 const (
 	CmdDanmu        = "DANMU_MSG"          // 弹幕消息
 	CmdGift         = "SEND_GIFT"          // 礼物消息
-	CmdWelcome      = "WELCOME"            // 欢迎消息
-	CmdFollow       = "NOTICE_MSG"         // 关注消息
+	CmdWelcome      = "WELCOME"            // 欢迎消息，旧版命令，已被INTERACT_WORD取代
 	CmdRoomChange   = "ROOM_CHANGE"        // 房间信息变更
 	CmdOnlineCount  = "ONLINE_RANK_COUNT"  // 在线人数
 	CmdComboSend    = "COMBO_SEND"         // 连击
 	CmdWelcomeGuard = "WELCOME_GUARD"      // 舰长进入
 	CmdGuardBuy     = "GUARD_BUY"          // 购买舰长
+	CmdUserToast    = "USER_TOAST_MSG"     // 大航海开通或续费提示，与GUARD_BUY成对下发
 	CmdSuperChat    = "SUPER_CHAT_MESSAGE" // SC消息
+
+	CmdInteractWord     = "INTERACT_WORD"                     // 进房、关注、分享
+	CmdInteractWordV2   = "INTERACT_WORD_V2"                  // 进房、关注、分享，data.pb为protobuf
+	CmdLikeClick        = "LIKE_INFO_V3_CLICK"                // 点赞
+	CmdLikeUpdate       = "LIKE_INFO_V3_UPDATE"               // 点赞总数
+	CmdWatchedChange    = "WATCHED_CHANGE"                    // 看过人数
+	CmdEntryEffect      = "ENTRY_EFFECT"                      // 进场特效
+	CmdLive             = "LIVE"                              // 开播
+	CmdPreparing        = "PREPARING"                         // 下播
+	CmdRoomBlock        = "ROOM_BLOCK_MSG"                    // 用户被禁言
+	CmdWarning          = "WARNING"                           // 超管警告
+	CmdCutOff           = "CUT_OFF"                           // 直播被切断
+	CmdSuperChatJPN     = "SUPER_CHAT_MESSAGE_JPN"            // 带翻译的SC消息，与SUPER_CHAT_MESSAGE重复下发
+	CmdSuperChatDelete  = "SUPER_CHAT_MESSAGE_DELETE"         // SC被删除
+	CmdOnlineRankV2     = "ONLINE_RANK_V2"                    // 高能榜
+	CmdOnlineRankV3     = "ONLINE_RANK_V3"                    // 高能榜，data.pb为protobuf
+	CmdRecallDanmu      = "RECALL_DANMU_MSG"                  // 弹幕被撤回
+	CmdRedPocketStart   = "POPULARITY_RED_POCKET_START"       // 人气红包开始
+	CmdRedPocketNew     = "POPULARITY_RED_POCKET_NEW"         // 新的人气红包
+	CmdRedPocketWinners = "POPULARITY_RED_POCKET_WINNER_LIST" // 人气红包中奖名单
 )
 
+// CmdFollow 曾被当作关注消息处理的命令
+//
+// Deprecated: NOTICE_MSG是全站广播，msg_type为2的也不是关注通知，已没有内置处理器，
+// 作为未知命令以原始消息透传。关注由INTERACT_WORD的msg_type区分
+const CmdFollow = "NOTICE_MSG"
+
+// Message 一条业务消息
+type Message struct {
+	Cmd    string       // 去掉参数后缀的命令，如DANMU_MSG
+	Raw    []byte       // 原始JSON
+	JSON   gjson.Result // 解析后的JSON
+	RoomID int          // 消息所属的真实房间号，由客户端填写
+}
+
 // ParseMessage 解析消息
-func ParseMessage(data []byte) (string, map[string]interface{}, error) {
+func ParseMessage(data []byte) (*Message, error) {
+	if !gjson.ValidBytes(data) {
+		return nil, errors.New("消息格式错误：不是合法的JSON")
+	}
+
 	// 解析JSON
 	result := gjson.ParseBytes(data)
 
 	cmd := result.Get("cmd").String()
 	if cmd == "" {
-		return "", nil, errors.New("消息格式错误：缺少cmd字段")
+		return nil, errors.New("消息格式错误：缺少cmd字段")
 	}
 
-	// 将数据转换为map
-	var msgData map[string]interface{}
-	err := json.Unmarshal(data, &msgData)
-	if err != nil {
-		return "", nil, err
+	// 旧版协议的命令可能带有参数后缀，如DANMU_MSG:4:0:2:2:2:0
+	if index := strings.IndexByte(cmd, ':'); index > 0 {
+		cmd = cmd[:index]
 	}
 
-	return cmd, msgData, nil
+	return &Message{
+		Cmd:  cmd,
+		Raw:  data,
+		JSON: result,
+	}, nil
+}
+
+// AuthParams 认证包参数
+type AuthParams struct {
+	RoomID   int
+	UID      int64  // 登录用户UID，游客为0
+	Buvid    string // 设备标识buvid3
+	Token    string // getDanmuInfo返回的token
+	Protover int    // 协商的协议版本
 }
 
 // BuildAuthMessage 构建认证消息
-func BuildAuthMessage(roomID int, token string) []byte {
+func BuildAuthMessage(params AuthParams) []byte {
 	authMsg := map[string]interface{}{
-		"roomid":    roomID,
-		"protover":  1,
+		"uid":       params.UID,
+		"roomid":    params.RoomID,
+		"protover":  params.Protover,
 		"platform":  "web",
 		"clientver": "1.4.0",
 		"type":      2,
 	}
 
-	if token != "" {
-		authMsg["key"] = token
+	if params.Buvid != "" {
+		authMsg["buvid"] = params.Buvid
+	}
+
+	if params.Token != "" {
+		authMsg["key"] = params.Token
 	}
 
 	data, _ := json.Marshal(authMsg)
@@ -67,40 +122,93 @@ type MessageInfo struct {
 	Timestamp int64                  `json:"timestamp,omitempty"`
 }
 
-// IsValidMessage 检查是否为有效消息
+// knownCmds 已支持解析的命令
+var knownCmds = map[string]bool{
+	CmdDanmu:            true,
+	CmdGift:             true,
+	CmdWelcome:          true,
+	CmdRoomChange:       true,
+	CmdOnlineCount:      true,
+	CmdComboSend:        true,
+	CmdWelcomeGuard:     true,
+	CmdGuardBuy:         true,
+	CmdUserToast:        true,
+	CmdSuperChat:        true,
+	CmdInteractWord:     true,
+	CmdInteractWordV2:   true,
+	CmdLikeClick:        true,
+	CmdLikeUpdate:       true,
+	CmdWatchedChange:    true,
+	CmdEntryEffect:      true,
+	CmdLive:             true,
+	CmdPreparing:        true,
+	CmdRoomBlock:        true,
+	CmdWarning:          true,
+	CmdCutOff:           true,
+	CmdSuperChatJPN:     true,
+	CmdSuperChatDelete:  true,
+	CmdOnlineRankV2:     true,
+	CmdOnlineRankV3:     true,
+	CmdRecallDanmu:      true,
+	CmdRedPocketStart:   true,
+	CmdRedPocketNew:     true,
+	CmdRedPocketWinners: true,
+}
+
+// IsValidMessage 检查是否为已知命令，未知命令仍会以原始消息透传
 func IsValidMessage(cmd string) bool {
-	validCmds := map[string]bool{
-		CmdDanmu:        true,
-		CmdGift:         true,
-		CmdWelcome:      true,
-		CmdFollow:       true,
-		CmdRoomChange:   true,
-		CmdOnlineCount:  true,
-		CmdComboSend:    true,
-		CmdWelcomeGuard: true,
-		CmdGuardBuy:     true,
-		CmdSuperChat:    true,
-	}
+	return knownCmds[cmd]
+}
 
-	return validCmds[cmd]
+// 消息优先级范围，数值越小越重要
+const (
+	PriorityProtected = 2 // 不高于此值的消息在队列满时也不会被丢弃
+	PriorityLowest    = 9 // 未知命令的优先级
+)
+
+// priorities 各命令的优先级
+var priorities = map[string]int{
+	CmdSuperChat:       1, // 最高优先级
+	CmdSuperChatJPN:    1,
+	CmdSuperChatDelete: 1,
+	CmdGuardBuy:        2,
+	CmdUserToast:       2,
+	CmdLive:            2,
+	CmdPreparing:       2,
+	CmdRoomChange:      2,
+	CmdWarning:         2,
+	CmdCutOff:          2,
+
+	CmdGift:             3,
+	CmdComboSend:        3,
+	CmdRedPocketStart:   3,
+	CmdRedPocketNew:     3,
+	CmdRedPocketWinners: 3,
+
+	CmdDanmu:       4,
+	CmdRecallDanmu: 4,
+	CmdRoomBlock:   4,
+
+	CmdWelcome:        5,
+	CmdWelcomeGuard:   5,
+	CmdInteractWord:   5,
+	CmdInteractWordV2: 5,
+	CmdEntryEffect:    5,
+
+	CmdLikeClick: 6,
+
+	CmdOnlineCount:   7,
+	CmdOnlineRankV2:  7,
+	CmdOnlineRankV3:  7,
+	CmdWatchedChange: 7,
+	CmdLikeUpdate:    7,
 }
 
 // GetMessagePriority 获取消息优先级
 func GetMessagePriority(cmd string) int {
-	priorities := map[string]int{
-		CmdSuperChat:   1, // 最高优先级
-		CmdGuardBuy:    2,
-		CmdGift:        3,
-		CmdDanmu:       4,
-		CmdWelcome:     5,
-		CmdFollow:      6,
-		CmdOnlineCount: 7,
-		CmdRoomChange:  8,
-	}
-
 	if priority, exists := priorities[cmd]; exists {
 		return priority
 	}
 
-	return 9 // 默认最低优先级
+	return PriorityLowest // 默认最低优先级
 }
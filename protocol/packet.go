@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
-	"fmt"
 )
 
 const (
@@ -16,9 +15,17 @@ const (
 	OpConnect        = 8 // 连接成功
 )
 
+const (
+	// 包头协议版本
+	ProtoVerPlain  = 0 // 未压缩的JSON消息
+	ProtoVerInt    = 1 // 心跳、认证等控制包
+	ProtoVerZlib   = 2 // zlib压缩的批量消息
+	ProtoVerBrotli = 3 // brotli压缩的批量消息
+)
+
 const (
 	// 协议版本
-	ProtocolVersion = 1
+	ProtocolVersion = ProtoVerInt
 	// 头部长度
 	HeaderLength = 16
 )
@@ -64,8 +71,18 @@ func DecodePacket(data []byte) (*Packet, error) {
 	binary.Read(buf, binary.BigEndian, &packet.Operation)
 	binary.Read(buf, binary.BigEndian, &packet.SequenceID)
 
-	if len(data) > HeaderLength {
-		packet.Body = data[HeaderLength:]
+	// 按包头记录的长度截取包体，长度异常时退回到剩余全部数据
+	bodyStart := int(packet.HeaderLength)
+	if bodyStart < HeaderLength || bodyStart > len(data) {
+		bodyStart = HeaderLength
+	}
+	bodyEnd := int(packet.PacketLength)
+	if bodyEnd < bodyStart || bodyEnd > len(data) {
+		bodyEnd = len(data)
+	}
+
+	if bodyEnd > bodyStart {
+		packet.Body = data[bodyStart:bodyEnd]
 	}
 
 	return packet, nil
@@ -82,10 +99,9 @@ func NewHeartbeatPacket() *Packet {
 	}
 }
 
-// 创建认证包
-func NewAuthPacket(roomID int, token string) *Packet {
-	authData := fmt.Sprintf(`{"roomid":%d,"protover":1,"platform":"web","clientver":"1.4.0","type":2,"key":"%s"}`, roomID, token)
-	body := []byte(authData)
+// 创建认证包，Protover决定服务器下发消息的压缩方式
+func NewAuthPacket(params AuthParams) *Packet {
+	body := BuildAuthMessage(params)
 
 	return &Packet{
 		PacketLength: int32(HeaderLength + len(body)),
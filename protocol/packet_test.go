@@ -0,0 +1,223 @@
+package protocol
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// testdata中的帧与服务器下发的格式一致：外层为压缩的批量包，解压后是多个未压缩的消息包
+func readGolden(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func encodePacket(version int16, operation int32, body []byte) []byte {
+	packet := &Packet{
+		PacketLength: int32(HeaderLength + len(body)),
+		HeaderLength: HeaderLength,
+		Version:      version,
+		Operation:    operation,
+		Body:         body,
+	}
+	return packet.Encode()
+}
+
+func packetCmds(t *testing.T, packets []*Packet) []string {
+	t.Helper()
+
+	var cmds []string
+	for _, packet := range packets {
+		if packet.Version != ProtoVerPlain || packet.Operation != OpMessage {
+			t.Fatalf("拆分结果中有未解压的包: version=%d operation=%d", packet.Version, packet.Operation)
+		}
+		var msg struct {
+			Cmd string `json:"cmd"`
+		}
+		if err := json.Unmarshal(packet.Body, &msg); err != nil {
+			t.Fatalf("包体不是JSON: %v", err)
+		}
+		cmds = append(cmds, msg.Cmd)
+	}
+	return cmds
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDecompressGolden(t *testing.T) {
+	tests := []struct {
+		file    string
+		version int16
+	}{
+		{"zlib_batch.bin", ProtoVerZlib},
+		{"brotli_batch.bin", ProtoVerBrotli},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			packet, err := DecodePacket(readGolden(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if packet.Version != tt.version || !packet.IsCompressed() {
+				t.Fatalf("version = %d, compressed = %v", packet.Version, packet.IsCompressed())
+			}
+
+			data, err := Decompress(packet.Version, packet.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 解压后的数据以一个未压缩消息包的包头开始
+			if len(data) < HeaderLength || binary.BigEndian.Uint16(data[6:8]) != ProtoVerPlain {
+				t.Fatalf("解压结果不是数据包: % x", data[:HeaderLength])
+			}
+		})
+	}
+}
+
+func TestDecompressErrors(t *testing.T) {
+	if _, err := Decompress(ProtoVerZlib, []byte("not zlib")); err == nil {
+		t.Error("损坏的zlib数据应返回错误")
+	}
+	if _, err := Decompress(ProtoVerPlain, []byte("{}")); err == nil {
+		t.Error("未压缩的协议版本应返回错误")
+	}
+}
+
+func TestSplitPacketsGolden(t *testing.T) {
+	tests := []struct {
+		file string
+		cmds []string
+	}{
+		{"zlib_batch.bin", []string{"DANMU_MSG", "SEND_GIFT", "INTERACT_WORD"}},
+		{"brotli_batch.bin", []string{"WATCHED_CHANGE", "ONLINE_RANK_COUNT", "SEND_GIFT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			packets, err := SplitPackets(readGolden(t, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cmds := packetCmds(t, packets); !equalStrings(cmds, tt.cmds) {
+				t.Errorf("cmds = %v, want %v", cmds, tt.cmds)
+			}
+		})
+	}
+}
+
+func TestSplitPacketsNested(t *testing.T) {
+	// brotli批量包中嵌套zlib批量包，同一帧末尾再拼接一个心跳回应
+	inner := append(readGolden(t, "zlib_batch.bin"), encodePacket(ProtoVerPlain, OpMessage, []byte(`{"cmd":"LIKE_INFO_V3_UPDATE","data":{"click_count":10}}`))...)
+
+	var buf bytes.Buffer
+	writer := brotli.NewWriter(&buf)
+	writer.Write(inner)
+	writer.Close()
+
+	popularity := make([]byte, 4)
+	binary.BigEndian.PutUint32(popularity, 1)
+	frame := append(encodePacket(ProtoVerBrotli, OpMessage, buf.Bytes()), encodePacket(ProtoVerInt, OpHeartbeatReply, popularity)...)
+
+	packets, err := SplitPackets(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packets) != 5 {
+		t.Fatalf("拆出 %d 个包，应为5个", len(packets))
+	}
+
+	cmds := packetCmds(t, packets[:4])
+	want := []string{"DANMU_MSG", "SEND_GIFT", "INTERACT_WORD", "LIKE_INFO_V3_UPDATE"}
+	if !equalStrings(cmds, want) {
+		t.Errorf("cmds = %v, want %v", cmds, want)
+	}
+	if packets[4].Operation != OpHeartbeatReply {
+		t.Errorf("最后一个包的operation = %d", packets[4].Operation)
+	}
+}
+
+func TestSplitPacketsNestDepth(t *testing.T) {
+	frame := encodePacket(ProtoVerPlain, OpMessage, []byte(`{"cmd":"DANMU_MSG"}`))
+	for i := 0; i <= maxNestDepth+1; i++ {
+		var buf bytes.Buffer
+		writer := zlib.NewWriter(&buf)
+		writer.Write(frame)
+		writer.Close()
+		frame = encodePacket(ProtoVerZlib, OpMessage, buf.Bytes())
+	}
+
+	if _, err := SplitPackets(frame); err == nil {
+		t.Error("超过嵌套上限时应返回错误")
+	}
+}
+
+func TestSplitPacketsTruncated(t *testing.T) {
+	first := encodePacket(ProtoVerPlain, OpMessage, []byte(`{"cmd":"DANMU_MSG"}`))
+	second := encodePacket(ProtoVerPlain, OpMessage, []byte(`{"cmd":"SEND_GIFT"}`))
+	frame := append(first, second[:len(second)-3]...)
+
+	packets, err := SplitPackets(frame)
+	if err == nil {
+		t.Fatal("截断的帧应返回错误")
+	}
+	if len(packets) != 1 {
+		t.Errorf("出错前应返回已拆出的1个包，实际 %d 个", len(packets))
+	}
+}
+
+func TestNewAuthPacket(t *testing.T) {
+	for _, protover := range []int{ProtoVerInt, ProtoVerZlib, ProtoVerBrotli} {
+		packet := NewAuthPacket(AuthParams{
+			RoomID:   21452505,
+			UID:      12345678,
+			Buvid:    "test-buvid",
+			Token:    "test-token",
+			Protover: protover,
+		})
+
+		encoded := packet.Encode()
+		if int(binary.BigEndian.Uint32(encoded[0:4])) != len(encoded) {
+			t.Errorf("protover %d: 包头长度 %d 与实际长度 %d 不符", protover, binary.BigEndian.Uint32(encoded[0:4]), len(encoded))
+		}
+		if packet.Operation != OpUserAuth || packet.Version != ProtoVerInt {
+			t.Errorf("protover %d: operation = %d, version = %d", protover, packet.Operation, packet.Version)
+		}
+
+		want := `{"buvid":"test-buvid","clientver":"1.4.0","key":"test-token","platform":"web","protover":` +
+			strconv.Itoa(protover) + `,"roomid":21452505,"type":2,"uid":12345678}`
+		if string(packet.Body) != want {
+			t.Errorf("protover %d: body = %s\nwant %s", protover, packet.Body, want)
+		}
+	}
+}
+
+func TestNewAuthPacketGuest(t *testing.T) {
+	packet := NewAuthPacket(AuthParams{RoomID: 21452505, Protover: ProtoVerBrotli})
+
+	want := `{"clientver":"1.4.0","platform":"web","protover":3,"roomid":21452505,"type":2,"uid":0}`
+	if string(packet.Body) != want {
+		t.Errorf("body = %s\nwant %s", packet.Body, want)
+	}
+}
@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 压缩包最大嵌套层数，防止异常数据无限递归
+const maxNestDepth = 4
+
+// SplitPackets 将一帧数据拆分为其中的所有数据包
+// 压缩的批量包会被解压并递归拆分，返回的数据包均为未压缩的包
+// 遇到错误时返回已成功拆分的数据包以及错误
+func SplitPackets(data []byte) ([]*Packet, error) {
+	var packets []*Packet
+	err := splitPackets(data, 0, func(packet *Packet) {
+		packets = append(packets, packet)
+	})
+	return packets, err
+}
+
+// ForEachPacket 依次回调一帧数据中的每个数据包，规则同SplitPackets
+func ForEachPacket(data []byte, fn func(packet *Packet)) error {
+	return splitPackets(data, 0, fn)
+}
+
+func splitPackets(data []byte, depth int, fn func(packet *Packet)) error {
+	if depth > maxNestDepth {
+		return fmt.Errorf("压缩包嵌套层数超过上限: %d", maxNestDepth)
+	}
+
+	offset := 0
+	for offset < len(data) {
+		if offset+HeaderLength > len(data) {
+			return fmt.Errorf("偏移 %d 处剩余数据不足一个包头", offset)
+		}
+
+		packetLength := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		if packetLength < HeaderLength || offset+packetLength > len(data) {
+			return fmt.Errorf("偏移 %d 处数据包长度异常: %d", offset, packetLength)
+		}
+
+		packet, err := DecodePacket(data[offset : offset+packetLength])
+		if err != nil {
+			return err
+		}
+		offset += packetLength
+
+		if !packet.IsCompressed() {
+			fn(packet)
+			continue
+		}
+
+		decompressed, err := Decompress(packet.Version, packet.Body)
+		if err != nil {
+			return err
+		}
+
+		if err := splitPackets(decompressed, depth+1, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
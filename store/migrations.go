@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// migrations 按顺序执行的表结构变更，已执行到的版本记录在PRAGMA user_version中
+// 已发布的变更不能修改，只能在末尾追加
+var migrations = []string{
+	// 1: 初始表结构，时间均为Unix毫秒
+	`
+	CREATE TABLE sessions (
+		id               TEXT PRIMARY KEY,
+		room_id          INTEGER NOT NULL,
+		live_key         TEXT NOT NULL DEFAULT '',
+		title            TEXT NOT NULL DEFAULT '',
+		area_name        TEXT NOT NULL DEFAULT '',
+		parent_area_name TEXT NOT NULL DEFAULT '',
+		cover            TEXT NOT NULL DEFAULT '',
+		start_time       INTEGER NOT NULL,
+		end_time         INTEGER
+	);
+	CREATE INDEX idx_sessions_room_time ON sessions (room_id, start_time);
+
+	CREATE TABLE danmu (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id     INTEGER NOT NULL,
+		session_id  TEXT NOT NULL DEFAULT '',
+		uid         INTEGER NOT NULL,
+		user_name   TEXT NOT NULL,
+		text        TEXT NOT NULL,
+		dm_type     INTEGER NOT NULL DEFAULT 0,
+		guard_level INTEGER NOT NULL DEFAULT 0,
+		medal_name  TEXT NOT NULL DEFAULT '',
+		medal_level INTEGER NOT NULL DEFAULT 0,
+		id_str      TEXT NOT NULL DEFAULT '',
+		recalled    INTEGER NOT NULL DEFAULT 0,
+		time        INTEGER NOT NULL
+	);
+	CREATE INDEX idx_danmu_room_time ON danmu (room_id, time);
+	CREATE INDEX idx_danmu_room_uid_time ON danmu (room_id, uid, time);
+	CREATE INDEX idx_danmu_session ON danmu (session_id);
+	CREATE INDEX idx_danmu_id_str ON danmu (id_str) WHERE id_str != '';
+
+	CREATE TABLE gifts (
+		id             INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id        INTEGER NOT NULL,
+		session_id     TEXT NOT NULL DEFAULT '',
+		uid            INTEGER NOT NULL,
+		user_name      TEXT NOT NULL,
+		gift_id        INTEGER NOT NULL,
+		gift_name      TEXT NOT NULL,
+		num            INTEGER NOT NULL,
+		coin_type      TEXT NOT NULL,
+		value          INTEGER NOT NULL,
+		cny            REAL NOT NULL,
+		batch_combo_id TEXT NOT NULL DEFAULT '',
+		time           INTEGER NOT NULL
+	);
+	CREATE INDEX idx_gifts_room_time ON gifts (room_id, time);
+	CREATE INDEX idx_gifts_room_uid_time ON gifts (room_id, uid, time);
+	CREATE INDEX idx_gifts_session ON gifts (session_id);
+
+	CREATE TABLE guards (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id     INTEGER NOT NULL,
+		session_id  TEXT NOT NULL DEFAULT '',
+		uid         INTEGER NOT NULL,
+		user_name   TEXT NOT NULL,
+		guard_level INTEGER NOT NULL,
+		num         INTEGER NOT NULL,
+		unit        TEXT NOT NULL DEFAULT '',
+		total_price INTEGER NOT NULL,
+		cny         REAL NOT NULL,
+		renewal     INTEGER NOT NULL DEFAULT 0,
+		time        INTEGER NOT NULL
+	);
+	CREATE INDEX idx_guards_room_time ON guards (room_id, time);
+	CREATE INDEX idx_guards_room_uid_time ON guards (room_id, uid, time);
+	CREATE INDEX idx_guards_session ON guards (session_id);
+
+	CREATE TABLE super_chats (
+		id            INTEGER PRIMARY KEY,
+		room_id       INTEGER NOT NULL,
+		session_id    TEXT NOT NULL DEFAULT '',
+		uid           INTEGER NOT NULL,
+		user_name     TEXT NOT NULL,
+		message       TEXT NOT NULL,
+		message_trans TEXT NOT NULL DEFAULT '',
+		price         INTEGER NOT NULL,
+		cny           REAL NOT NULL,
+		start_time    INTEGER NOT NULL,
+		end_time      INTEGER NOT NULL,
+		deleted       INTEGER NOT NULL DEFAULT 0,
+		time          INTEGER NOT NULL
+	);
+	CREATE INDEX idx_super_chats_room_time ON super_chats (room_id, time);
+	CREATE INDEX idx_super_chats_room_uid_time ON super_chats (room_id, uid, time);
+	CREATE INDEX idx_super_chats_session ON super_chats (session_id);
+
+	CREATE TABLE entries (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		room_id     INTEGER NOT NULL,
+		session_id  TEXT NOT NULL DEFAULT '',
+		uid         INTEGER NOT NULL,
+		user_name   TEXT NOT NULL,
+		guard_level INTEGER NOT NULL DEFAULT 0,
+		medal_name  TEXT NOT NULL DEFAULT '',
+		medal_level INTEGER NOT NULL DEFAULT 0,
+		time        INTEGER NOT NULL
+	);
+	CREATE INDEX idx_entries_room_time ON entries (room_id, time);
+	CREATE INDEX idx_entries_room_uid_time ON entries (room_id, uid, time);
+	`,
+}
+
+// migrate 执行尚未执行的变更，每个版本在单独的事务中执行
+func migrate(ctx context.Context, db *sql.DB) error {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("读取数据库版本失败: %v", err)
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("数据库版本 %d 高于程序支持的版本 %d", version, len(migrations))
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := applyMigration(ctx, db, i+1, migrations[i]); err != nil {
+			return fmt.Errorf("升级数据库到版本 %d 失败: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, version int, statements string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+	// PRAGMA不支持参数绑定
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"TianHe-API/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrNotFound 查询的记录不存在
+var ErrNotFound = errors.New("记录不存在")
+
+// SessionRevenue 一场直播的收入，只统计金瓜子礼物、大航海和未被删除的醒目留言
+type SessionRevenue struct {
+	Session      model.LiveSession `json:"session"`
+	GiftCNY      float64           `json:"gift_cny"`
+	GuardCNY     float64           `json:"guard_cny"`
+	SuperChatCNY float64           `json:"super_chat_cny"`
+	TotalCNY     float64           `json:"total_cny"`
+	Gifts        int               `json:"gifts"`       // 合并后的礼物条数
+	Guards       int               `json:"guards"`      // 开通、续费次数
+	SuperChats   int               `json:"super_chats"` // 醒目留言条数
+}
+
+// UserDanmu 获取用户在房间中从since开始发送的弹幕，按时间先后排序
+func (s *Store) UserDanmu(ctx context.Context, roomID int, uid int64, since time.Time) ([]model.DanmuMessage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT uid, user_name, text, dm_type, guard_level, medal_name, medal_level, id_str, time
+		FROM danmu
+		WHERE room_id = ? AND uid = ? AND time >= ?
+		ORDER BY time`,
+		roomID, uid, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.DanmuMessage
+	for rows.Next() {
+		var (
+			danmu      model.DanmuMessage
+			medalName  string
+			medalLevel int
+			timestamp  int64
+		)
+		err := rows.Scan(&danmu.UserID, &danmu.UserName, &danmu.Text, &danmu.DmType, &danmu.GuardLevel,
+			&medalName, &medalLevel, &danmu.IDStr, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		danmu.Timestamp = time.UnixMilli(timestamp)
+		if medalName != "" {
+			danmu.Medal = &model.FanMedal{Name: medalName, Level: medalLevel}
+		}
+		messages = append(messages, danmu)
+	}
+	return messages, rows.Err()
+}
+
+// Session 获取一场直播
+func (s *Store) Session(ctx context.Context, id string) (*model.LiveSession, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id)
+
+	session, err := scanSession(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return session, err
+}
+
+// Sessions 获取房间从since开始的直播场次，按开播时间先后排序
+func (s *Store) Sessions(ctx context.Context, roomID int, since time.Time) ([]model.LiveSession, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE room_id = ? AND start_time >= ?
+		ORDER BY start_time`,
+		roomID, since.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []model.LiveSession
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revenue 统计房间从since开始每场直播的收入，按开播时间先后排序
+func (s *Store) Revenue(ctx context.Context, roomID int, since time.Time) ([]SessionRevenue, error) {
+	sessions, err := s.Sessions(ctx, roomID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	revenues := make([]SessionRevenue, 0, len(sessions))
+	for _, session := range sessions {
+		revenue, err := s.sessionRevenue(ctx, session)
+		if err != nil {
+			return nil, err
+		}
+		revenues = append(revenues, *revenue)
+	}
+	return revenues, nil
+}
+
+// SessionRevenue 统计一场直播的收入
+func (s *Store) SessionRevenue(ctx context.Context, id string) (*SessionRevenue, error) {
+	session, err := s.Session(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.sessionRevenue(ctx, *session)
+}
+
+func (s *Store) sessionRevenue(ctx context.Context, session model.LiveSession) (*SessionRevenue, error) {
+	revenue := &SessionRevenue{Session: session}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COALESCE(SUM(cny), 0) FROM gifts WHERE session_id = ?1),
+			(SELECT COUNT(*) FROM gifts WHERE session_id = ?1),
+			(SELECT COALESCE(SUM(cny), 0) FROM guards WHERE session_id = ?1),
+			(SELECT COUNT(*) FROM guards WHERE session_id = ?1),
+			(SELECT COALESCE(SUM(cny), 0) FROM super_chats WHERE session_id = ?1 AND deleted = 0),
+			(SELECT COUNT(*) FROM super_chats WHERE session_id = ?1 AND deleted = 0)`,
+		session.ID,
+	).Scan(&revenue.GiftCNY, &revenue.Gifts, &revenue.GuardCNY, &revenue.Guards, &revenue.SuperChatCNY, &revenue.SuperChats)
+	if err != nil {
+		return nil, err
+	}
+
+	revenue.TotalCNY = revenue.GiftCNY + revenue.GuardCNY + revenue.SuperChatCNY
+	return revenue, nil
+}
+
+const sessionColumns = `id, room_id, live_key, title, area_name, parent_area_name, cover, start_time, end_time`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*model.LiveSession, error) {
+	var (
+		session   model.LiveSession
+		startTime int64
+		endTime   sql.NullInt64
+	)
+	err := row.Scan(&session.ID, &session.RoomID, &session.LiveKey, &session.Title, &session.AreaName,
+		&session.ParentAreaName, &session.Cover, &startTime, &endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	session.StartTime = time.UnixMilli(startTime)
+	if endTime.Valid {
+		session.EndTime = time.UnixMilli(endTime.Int64)
+	}
+	return &session, nil
+}
@@ -0,0 +1,300 @@
+package store
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Options 存储参数
+type Options struct {
+	Path          string        // 数据库文件路径
+	BatchSize     int           // 攒够多少条事件写入一次
+	FlushInterval time.Duration // 事件不足一批时最多等待多久写入
+	QueueSize     int           // 待写入事件的队列长度，满时丢弃新事件
+}
+
+// Store 基于SQLite的直播间历史记录
+//
+// 事件先进入队列，由单独的协程按批在事务中写入，不阻塞消息处理
+type Store struct {
+	db      *sql.DB
+	options Options
+	queue   chan *event.Event
+	dropped uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// 需要存储的事件类型
+var storedTypes = map[event.Type]bool{
+	event.TypeDanmu:           true,
+	event.TypeRecallDanmu:     true,
+	event.TypeGiftSettled:     true,
+	event.TypeGuard:           true,
+	event.TypeSuperChat:       true,
+	event.TypeSuperChatDelete: true,
+	event.TypeWelcome:         true,
+	event.TypeSessionStart:    true,
+	event.TypeSessionEnd:      true,
+	event.TypeRoomChange:      true,
+}
+
+// Open 打开数据库并升级表结构，文件不存在时自动创建
+func Open(ctx context.Context, options Options) (*Store, error) {
+	if options.BatchSize <= 0 {
+		options.BatchSize = 200
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 4096
+	}
+
+	if err := utils.EnsureDir(utils.GetFileDir(options.Path)); err != nil {
+		return nil, err
+	}
+
+	dsn := "file:" + options.Path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库失败: %v", err)
+	}
+	// SQLite同一时间只允许一个写入者，单连接避免写入时互相等待
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Store{
+		db:      db,
+		options: options,
+		queue:   make(chan *event.Event, options.QueueSize),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Handle 把事件放入写入队列，不需要存储的事件直接忽略，可直接传给Bus.SubscribeFunc
+func (s *Store) Handle(e *event.Event) {
+	if !storedTypes[e.Type] {
+		return
+	}
+
+	select {
+	case <-s.done:
+	case s.queue <- e:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+// Dropped 因队列已满而丢弃的事件数
+func (s *Store) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close 写入队列中剩余的事件并关闭数据库
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+	return s.db.Close()
+}
+
+func (s *Store) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.options.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*event.Event, 0, s.options.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.write(batch); err != nil {
+			utils.Logger.Errorf("写入 %d 条事件失败: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e := <-s.queue:
+			batch = append(batch, e)
+			if len(batch) >= s.options.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case e := <-s.queue:
+					batch = append(batch, e)
+					if len(batch) >= s.options.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// write 在一个事务中写入一批事件，单个事件写入失败时记录日志并跳过，不影响同一批的其它事件
+func (s *Store) write(batch []*event.Event) error {
+	ctx := context.Background()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 同一批中的语句只准备一次
+	stmts := make(map[string]*sql.Stmt)
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+	exec := func(query string, args ...interface{}) error {
+		stmt, ok := stmts[query]
+		if !ok {
+			var err error
+			stmt, err = tx.PrepareContext(ctx, query)
+			if err != nil {
+				return err
+			}
+			stmts[query] = stmt
+		}
+		_, err := stmt.ExecContext(ctx, args...)
+		return err
+	}
+
+	// 每个事件使用一个保存点，失败时只回滚这个事件的写入
+	for _, e := range batch {
+		if err := exec("SAVEPOINT event"); err != nil {
+			return err
+		}
+		if err := writeEvent(exec, e); err != nil {
+			utils.Logger.Errorf("房间 %d 写入%s事件失败，已跳过: %v", e.RoomID, e.Type, err)
+			if err := exec("ROLLBACK TO event"); err != nil {
+				return err
+			}
+		}
+		if err := exec("RELEASE event"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const (
+	insertDanmu = `INSERT INTO danmu (room_id, session_id, uid, user_name, text, dm_type, guard_level, medal_name, medal_level, id_str, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	recallDanmu = `UPDATE danmu SET recalled = 1 WHERE id_str = ? AND room_id = ?`
+	insertGift  = `INSERT INTO gifts (room_id, session_id, uid, user_name, gift_id, gift_name, num, coin_type, value, cny, batch_combo_id, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	insertGuard = `INSERT INTO guards (room_id, session_id, uid, user_name, guard_level, num, unit, total_price, cny, renewal, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	insertSuperChat = `INSERT OR IGNORE INTO super_chats (id, room_id, session_id, uid, user_name, message, message_trans, price, cny, start_time, end_time, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	deleteSuperChat = `UPDATE super_chats SET deleted = 1 WHERE id = ?`
+	insertEntry     = `INSERT INTO entries (room_id, session_id, uid, user_name, guard_level, medal_name, medal_level, time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	upsertSession = `INSERT INTO sessions (id, room_id, live_key, title, area_name, parent_area_name, cover, start_time, end_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			live_key = excluded.live_key, title = excluded.title, area_name = excluded.area_name,
+			parent_area_name = excluded.parent_area_name, cover = excluded.cover, end_time = excluded.end_time`
+	updateSessionRoom = `UPDATE sessions SET title = ?, area_name = ?, parent_area_name = ? WHERE id = ?`
+)
+
+func writeEvent(exec func(query string, args ...interface{}) error, e *event.Event) error {
+	switch e.Type {
+	case event.TypeDanmu:
+		danmu, _ := e.Danmu()
+		medalName, medalLevel := medalColumns(danmu.Medal)
+		return exec(insertDanmu, e.RoomID, e.SessionID, danmu.UserID, danmu.UserName, danmu.Text, danmu.DmType,
+			danmu.GuardLevel, medalName, medalLevel, danmu.IDStr, unixMilli(danmu.Timestamp, e.Time))
+	case event.TypeRecallDanmu:
+		recall, _ := e.RecallDanmu()
+		return exec(recallDanmu, recall.IDStr, e.RoomID)
+	case event.TypeGiftSettled:
+		gift, _ := e.GiftSettled()
+		return exec(insertGift, e.RoomID, e.SessionID, gift.UserID, gift.UserName, gift.GiftID, gift.GiftName,
+			gift.Num, gift.CoinType, gift.Value, gift.CNY, gift.BatchComboID, unixMilli(gift.StartTime, e.Time))
+	case event.TypeGuard:
+		guard, _ := e.Guard()
+		return exec(insertGuard, e.RoomID, e.SessionID, guard.UserID, guard.UserName, guard.GuardLevel, guard.Num,
+			guard.Unit, guard.TotalPrice, guard.CNY, guard.Renewal, unixMilli(guard.Timestamp, e.Time))
+	case event.TypeSuperChat:
+		sc, _ := e.SuperChat()
+		return exec(insertSuperChat, sc.ID, e.RoomID, e.SessionID, sc.UserID, sc.UserName, sc.Message, sc.MessageTrans,
+			sc.Price, sc.CNY, unixMilli(sc.StartTime, e.Time), unixMilli(sc.EndTime, e.Time), unixMilli(sc.Timestamp, e.Time))
+	case event.TypeSuperChatDelete:
+		deleted, _ := e.SuperChatDelete()
+		for _, id := range deleted.IDs {
+			if err := exec(deleteSuperChat, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	case event.TypeWelcome:
+		welcome, _ := e.Welcome()
+		medalName, medalLevel := medalColumns(welcome.Medal)
+		return exec(insertEntry, e.RoomID, e.SessionID, welcome.UserID, welcome.UserName, welcome.GuardLevel,
+			medalName, medalLevel, unixMilli(welcome.Timestamp, e.Time))
+	case event.TypeSessionStart, event.TypeSessionEnd:
+		session, _ := e.Session()
+		var endTime interface{}
+		if !session.EndTime.IsZero() {
+			endTime = session.EndTime.UnixMilli()
+		}
+		return exec(upsertSession, session.ID, session.RoomID, session.LiveKey, session.Title, session.AreaName,
+			session.ParentAreaName, session.Cover, session.StartTime.UnixMilli(), endTime)
+	case event.TypeRoomChange:
+		if e.SessionID == "" {
+			return nil
+		}
+		change, _ := e.RoomChange()
+		return exec(updateSessionRoom, change.Title, change.AreaName, change.ParentAreaName, e.SessionID)
+	}
+	return nil
+}
+
+func medalColumns(medal *model.FanMedal) (string, int) {
+	if medal == nil {
+		return "", 0
+	}
+	return medal.Name, medal.Level
+}
+
+// unixMilli 消息自带的时间缺失时使用事件时间
+func unixMilli(t, fallback time.Time) int64 {
+	if t.IsZero() {
+		t = fallback
+	}
+	return t.UnixMilli()
+}
@@ -0,0 +1,219 @@
+package store
+
+import (
+	"TianHe-API/event"
+	"TianHe-API/model"
+	"TianHe-API/utils"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	utils.InitLogger()
+	os.Exit(m.Run())
+}
+
+const testRoomID = 21452505
+
+// openTestStore 在临时目录中打开数据库
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	s, err := Open(context.Background(), Options{Path: filepath.Join(t.TempDir(), "live.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func danmuEvent(text string) *event.Event {
+	return danmuAt(testRoomID, 12345678, text, time.Now())
+}
+
+func danmuAt(roomID int, uid int64, text string, at time.Time) *event.Event {
+	return &event.Event{
+		Type:   event.TypeDanmu,
+		RoomID: roomID,
+		Time:   at,
+		Data: &model.DanmuMessage{
+			UserID:    uid,
+			UserName:  "测试观众",
+			Text:      text,
+			Timestamp: at,
+		},
+	}
+}
+
+func TestWriteSkipsFailedEvent(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	// 用触发器让其中一条弹幕写入失败
+	_, err := s.db.ExecContext(ctx, `
+		CREATE TRIGGER reject_danmu BEFORE INSERT ON danmu WHEN NEW.text = '坏弹幕'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := []*event.Event{danmuEvent("第一条"), danmuEvent("坏弹幕"), danmuEvent("第二条")}
+	if err := s.write(batch); err != nil {
+		t.Fatalf("一条事件失败导致整批失败: %v", err)
+	}
+
+	messages, err := s.UserDanmu(ctx, testRoomID, 12345678, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Text != "第一条" || messages[1].Text != "第二条" {
+		t.Errorf("写入的弹幕 = %+v", messages)
+	}
+}
+
+func TestUserDanmu(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	base := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	batch := []*event.Event{
+		danmuAt(testRoomID, 12345678, "太早了", base.Add(-time.Hour)),
+		danmuAt(testRoomID, 12345678, "第一条", base),
+		danmuAt(testRoomID, 87654321, "别人的", base.Add(time.Minute)),
+		danmuAt(1, 12345678, "别的房间", base.Add(time.Minute)),
+		danmuAt(testRoomID, 12345678, "第二条", base.Add(2*time.Minute)),
+	}
+	if err := s.write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, err := s.UserDanmu(ctx, testRoomID, 12345678, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Text != "第一条" || messages[1].Text != "第二条" {
+		t.Fatalf("弹幕 = %+v", messages)
+	}
+	if !messages[1].Timestamp.Equal(base.Add(2 * time.Minute)) {
+		t.Errorf("Timestamp = %v", messages[1].Timestamp)
+	}
+
+	other, err := s.UserDanmu(ctx, 1, 12345678, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other) != 1 || other[0].Text != "别的房间" {
+		t.Errorf("其它房间的弹幕 = %+v", other)
+	}
+}
+
+func TestRevenue(t *testing.T) {
+	ctx := context.Background()
+	s := openTestStore(t)
+
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	session := &model.LiveSession{ID: "21452505-1704139200", RoomID: testRoomID, Title: "测试直播", StartTime: start}
+	earlier := &model.LiveSession{ID: "21452505-1704052800", RoomID: testRoomID, StartTime: start.Add(-24 * time.Hour), EndTime: start.Add(-20 * time.Hour)}
+	inSession := func(t event.Type, data interface{}) *event.Event {
+		return &event.Event{Type: t, RoomID: testRoomID, SessionID: session.ID, Time: start.Add(time.Minute), Data: data}
+	}
+
+	batch := []*event.Event{
+		{Type: event.TypeSessionStart, RoomID: testRoomID, Time: earlier.StartTime, Data: earlier},
+		{Type: event.TypeSessionStart, RoomID: testRoomID, Time: start, Data: session},
+		inSession(event.TypeGiftSettled, &model.GiftSettledMessage{GiftName: "小花花", Num: 10, CoinType: model.CoinGold, Value: 1000, CNY: 1}),
+		inSession(event.TypeGiftSettled, &model.GiftSettledMessage{GiftName: "辣条", Num: 10, CoinType: model.CoinSilver, Value: 1000}),
+		inSession(event.TypeGuard, &model.GuardMessage{UserID: 1, GuardLevel: 3, Num: 1, TotalPrice: 198000, CNY: 198}),
+		inSession(event.TypeSuperChat, &model.SuperChatMessage{ID: 1, UserID: 2, Message: "留言", Price: 30, CNY: 30}),
+		inSession(event.TypeSuperChat, &model.SuperChatMessage{ID: 2, UserID: 3, Message: "被删除", Price: 50, CNY: 50}),
+		// 重复下发的醒目留言只记一次
+		inSession(event.TypeSuperChat, &model.SuperChatMessage{ID: 1, UserID: 2, Message: "留言", Price: 30, CNY: 30}),
+		inSession(event.TypeSuperChatDelete, &model.SuperChatDeleteMessage{IDs: []int64{2}}),
+	}
+	if err := s.write(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	revenue, err := s.SessionRevenue(ctx, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revenue.GiftCNY != 1 || revenue.Gifts != 2 || revenue.GuardCNY != 198 || revenue.Guards != 1 {
+		t.Errorf("礼物和大航海 = %+v", revenue)
+	}
+	if revenue.SuperChatCNY != 30 || revenue.SuperChats != 1 {
+		t.Errorf("被删除的醒目留言也计入了收入: %+v", revenue)
+	}
+	if revenue.TotalCNY != 229 || revenue.Session.Title != "测试直播" {
+		t.Errorf("合计 = %v, 场次 = %+v", revenue.TotalCNY, revenue.Session)
+	}
+
+	revenues, err := s.Revenue(ctx, testRoomID, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revenues) != 2 || revenues[0].Session.ID != earlier.ID || revenues[0].TotalCNY != 0 || revenues[1].TotalCNY != 229 {
+		t.Errorf("每场收入 = %+v", revenues)
+	}
+	if !revenues[0].Session.EndTime.Equal(earlier.EndTime) || !revenues[1].Session.EndTime.IsZero() {
+		t.Errorf("下播时间 = %v %v", revenues[0].Session.EndTime, revenues[1].Session.EndTime)
+	}
+
+	if _, err := s.SessionRevenue(ctx, "不存在"); err != ErrNotFound {
+		t.Errorf("不存在的场次 err = %v", err)
+	}
+}
+
+func TestMigrateTwice(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "live.db")
+
+	s, err := Open(ctx, Options{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write([]*event.Event{danmuEvent("升级前")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已是最新版本时再次执行不做任何变更
+	if err := migrate(ctx, s.db); err != nil {
+		t.Fatalf("再次升级失败: %v", err)
+	}
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != len(migrations) {
+		t.Errorf("user_version = %d, want %d", version, len(migrations))
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开同一个文件，数据保留
+	s, err = Open(ctx, Options{Path: path})
+	if err != nil {
+		t.Fatalf("重新打开失败: %v", err)
+	}
+	defer s.Close()
+
+	messages, err := s.UserDanmu(ctx, testRoomID, 12345678, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 {
+		t.Errorf("重新打开后有 %d 条弹幕", len(messages))
+	}
+
+	// 数据库版本高于程序支持的版本时拒绝打开
+	if _, err := s.db.ExecContext(ctx, "PRAGMA user_version = 999"); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate(ctx, s.db); err == nil {
+		t.Error("版本过高时应返回错误")
+	}
+}